@@ -0,0 +1,160 @@
+package pingorm
+
+import (
+	"database/sql"
+	"fmt"
+
+	"gorm.io/gorm"
+)
+
+type AggregateFunc string
+
+const (
+	AggregateSum AggregateFunc = "SUM"
+	AggregateMin AggregateFunc = "MIN"
+	AggregateMax AggregateFunc = "MAX"
+	AggregateAvg AggregateFunc = "AVG"
+)
+
+type (
+	// Aggregate computes Func of the numeric Field, per group of GroupBy
+	// fields when given.
+	Aggregate struct {
+		Func    AggregateFunc
+		Field   string
+		GroupBy []string
+	}
+
+	// AggregateResult holds the values of the GroupBy fields in order, Value
+	// is nil when the aggregated values are all NULL.
+	AggregateResult struct {
+		GroupValues []interface{}
+		Value       *float64
+	}
+)
+
+func (repo Repo) Count(_db interface{}, sliceOfIDs interface{}, option QuerySelector) (int64, error) {
+	return count(_db.(*gorm.DB), repo.Model, sliceOfIDs, option)
+}
+
+func (repo Repo) Exists(_db interface{}, sliceOfIDs interface{}, option QuerySelector) (bool, error) {
+	return exists(_db.(*gorm.DB), repo.Model, sliceOfIDs, option)
+}
+
+func (repo Repo) Aggregate(_db interface{}, sliceOfIDs interface{}, aggregate Aggregate, option QuerySelector) ([]AggregateResult, error) {
+	return aggregateBy(_db.(*gorm.DB), repo.Model, sliceOfIDs, aggregate, option)
+}
+
+func (repo TypedRepo[T]) Count(db *gorm.DB, sliceOfIDs interface{}, option QuerySelector) (int64, error) {
+	return count(db, new(T), sliceOfIDs, option)
+}
+
+func (repo TypedRepo[T]) Exists(db *gorm.DB, sliceOfIDs interface{}, option QuerySelector) (bool, error) {
+	return exists(db, new(T), sliceOfIDs, option)
+}
+
+func (repo TypedRepo[T]) Aggregate(db *gorm.DB, sliceOfIDs interface{}, aggregate Aggregate, option QuerySelector) ([]AggregateResult, error) {
+	return aggregateBy(db, new(T), sliceOfIDs, aggregate, option)
+}
+
+func count(db *gorm.DB, model interface{}, sliceOfIDs interface{}, option QuerySelector) (int64, error) {
+	modelSchema, err := parseSchema(db, model)
+	if err != nil {
+		return 0, err
+	}
+
+	if db, err = whereKeysAndFilters(db, modelSchema, sliceOfIDs, option); err != nil {
+		return 0, err
+	}
+
+	var total int64
+	err = db.Model(model).Count(&total).Error
+	return total, err
+}
+
+func exists(db *gorm.DB, model interface{}, sliceOfIDs interface{}, option QuerySelector) (bool, error) {
+	modelSchema, err := parseSchema(db, model)
+	if err != nil {
+		return false, err
+	}
+
+	if db, err = whereKeysAndFilters(db, modelSchema, sliceOfIDs, option); err != nil {
+		return false, err
+	}
+
+	var found []int
+	err = db.Model(model).Select("1").Limit(1).Find(&found).Error
+	return len(found) > 0, err
+}
+
+func aggregateBy(db *gorm.DB, model interface{}, sliceOfIDs interface{}, aggregate Aggregate, option QuerySelector) ([]AggregateResult, error) {
+	modelSchema, err := parseSchema(db, model)
+	if err != nil {
+		return nil, err
+	}
+
+	switch aggregate.Func {
+	case AggregateSum, AggregateMin, AggregateMax, AggregateAvg:
+	default:
+		return nil, invalidArgument("unsupported aggregate function %s", aggregate.Func)
+	}
+
+	colName := db.NamingStrategy.ColumnName("", aggregate.Field)
+	if _, ok := modelSchema.FieldsByDBName[colName]; !ok {
+		return nil, invalidArgument("field %s is not a column of %s", aggregate.Field, modelSchema.Name)
+	}
+
+	var groupCols []string
+	for _, groupBy := range aggregate.GroupBy {
+		groupCol := db.NamingStrategy.ColumnName("", groupBy)
+		if _, ok := modelSchema.FieldsByDBName[groupCol]; !ok {
+			return nil, invalidArgument("field %s is not a column of %s", groupBy, modelSchema.Name)
+		}
+		groupCols = append(groupCols, groupCol)
+	}
+
+	if db, err = whereKeysAndFilters(db, modelSchema, sliceOfIDs, option); err != nil {
+		return nil, err
+	}
+
+	selects := append(append([]string{}, groupCols...),
+		fmt.Sprintf("%s(%s)", aggregate.Func, db.Statement.Quote(colName)))
+	db = db.Model(model).Select(selects)
+	for _, groupCol := range groupCols {
+		db = db.Group(groupCol).Order(groupCol)
+	}
+
+	rows, err := db.Rows()
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	results := []AggregateResult{}
+	for rows.Next() {
+		groupValues := make([]interface{}, len(groupCols))
+		dest := make([]interface{}, 0, len(groupCols)+1)
+		for i := range groupValues {
+			dest = append(dest, &groupValues[i])
+		}
+		var value sql.NullFloat64
+		dest = append(dest, &value)
+
+		if err := rows.Scan(dest...); err != nil {
+			return nil, err
+		}
+
+		for i, groupValue := range groupValues {
+			if bytes, ok := groupValue.([]byte); ok {
+				groupValues[i] = string(bytes)
+			}
+		}
+
+		result := AggregateResult{GroupValues: groupValues}
+		if value.Valid {
+			result.Value = &value.Float64
+		}
+		results = append(results, result)
+	}
+	return results, rows.Err()
+}
@@ -0,0 +1,148 @@
+package pingorm
+
+import (
+	"testing"
+
+	"github.com/icza/gox/gox"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCountAndExists(t *testing.T) {
+
+	seeds := []interface{}{
+		&Author{ID: 1, Name: "Henglong", Sex: "Male"},
+		&Author{ID: 2, Name: "Vicheka", Sex: "Male"},
+		&Author{ID: 3, Name: "Vichheka", Sex: "Female"},
+	}
+
+	tests := []struct {
+		inputIDs    interface{}
+		queryParams QueryOption
+		expCount    int64
+		expExists   bool
+		expErr      error
+	}{
+		// It should count all the rows but the soft deleted ID 3
+		{
+			inputIDs:  nil,
+			expCount:  2,
+			expExists: true,
+		},
+		{
+			inputIDs:  []uint32{1, 3},
+			expCount:  1,
+			expExists: true,
+		},
+		{
+			inputIDs:    nil,
+			queryParams: QueryOption{Filters: []Filter{Eq("Sex", "Female")}},
+			expCount:    0,
+			expExists:   false,
+		},
+		{
+			inputIDs:    nil,
+			queryParams: QueryOption{Filters: []Filter{Eq("Title", "Pingorm")}},
+			expErr:      &InvalidArgumentError{Reason: "field Title is not a column of Author"},
+		},
+	}
+
+	for _, tc := range tests {
+		func() {
+			req := require.New(t)
+
+			cleanTables()
+
+			db, err := OpenDb(dbConString)
+			req.Nil(err)
+			db = db.Debug()
+
+			for _, seed := range seeds {
+				err = db.Create(seed).Error
+				req.Nil(err)
+			}
+			req.Nil(db.Delete(&Author{}, 3).Error)
+
+			total, errCount := Repo{Model: &Author{}}.Count(db, tc.inputIDs, tc.queryParams)
+			req.Equal(tc.expErr, errCount)
+			req.Equal(tc.expCount, total)
+
+			found, errExists := TypedRepo[Author]{}.Exists(db, tc.inputIDs, tc.queryParams)
+			req.Equal(tc.expErr, errExists)
+			req.Equal(tc.expExists, found)
+		}()
+	}
+}
+
+func TestAggregate(t *testing.T) {
+
+	seeds := []interface{}{
+		&Author{ID: 1, Name: "Henglong"},
+		&Author{ID: 2, Name: "Vicheka"},
+		&Editor{ID: 1, Name: "Vichheka"},
+		&Book{ID: 1, AuthorID: 1, EditorID: 1, Title: "A"},
+		&Book{ID: 2, AuthorID: 1, EditorID: 1, Title: "B"},
+		&Book{ID: 3, AuthorID: 2, EditorID: 1, Title: "C"},
+		&Book{ID: 4, AuthorID: 2, EditorID: 1, Title: "D"},
+	}
+
+	tests := []struct {
+		aggregate   Aggregate
+		queryParams QueryOption
+		expGot      []AggregateResult
+		expErr      error
+	}{
+		{
+			aggregate: Aggregate{Func: AggregateSum, Field: "ID"},
+			expGot: []AggregateResult{
+				{GroupValues: []interface{}{}, Value: gox.NewFloat64(10)},
+			},
+		},
+		{
+			aggregate: Aggregate{Func: AggregateMax, Field: "ID", GroupBy: []string{"AuthorID"}},
+			expGot: []AggregateResult{
+				{GroupValues: []interface{}{int64(1)}, Value: gox.NewFloat64(2)},
+				{GroupValues: []interface{}{int64(2)}, Value: gox.NewFloat64(4)},
+			},
+		},
+		{
+			aggregate:   Aggregate{Func: AggregateAvg, Field: "ID", GroupBy: []string{"Title"}},
+			queryParams: QueryOption{Filters: []Filter{In("Title", "A", "D")}},
+			expGot: []AggregateResult{
+				{GroupValues: []interface{}{"A"}, Value: gox.NewFloat64(1)},
+				{GroupValues: []interface{}{"D"}, Value: gox.NewFloat64(4)},
+			},
+		},
+		{
+			aggregate:   Aggregate{Func: AggregateMin, Field: "ID"},
+			queryParams: QueryOption{Filters: []Filter{Eq("Title", "E")}},
+			expGot: []AggregateResult{
+				{GroupValues: []interface{}{}},
+			},
+		},
+		{
+			aggregate: Aggregate{Func: "COUNT", Field: "ID"},
+			expErr:    &InvalidArgumentError{Reason: "unsupported aggregate function COUNT"},
+		},
+	}
+
+	for _, tc := range tests {
+		func() {
+			req := require.New(t)
+
+			cleanTables()
+
+			db, err := OpenDb(dbConString)
+			req.Nil(err)
+			db = db.Debug()
+
+			for _, seed := range seeds {
+				err = db.Create(seed).Error
+				req.Nil(err)
+			}
+
+			got, errAggregate := TypedRepo[Book]{}.Aggregate(db, nil, tc.aggregate, tc.queryParams)
+			req.Equal(tc.expErr, errAggregate)
+			req.Equal(tc.expGot, got)
+		}()
+	}
+}
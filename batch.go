@@ -0,0 +1,199 @@
+package pingorm
+
+import (
+	"fmt"
+	"reflect"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/schema"
+)
+
+const defaultBatchSize = 1000
+
+// BatchError reports the chunk of a batched write which failed, it holds the
+// rows of the input from Start up to End excluded. The chunks written before
+// are rolled back along.
+type BatchError struct {
+	Chunk int
+	Start int
+	End   int
+	Err   error
+}
+
+func (err *BatchError) Error() string {
+	return fmt.Sprintf("chunk %d of rows [%d, %d): %v", err.Chunk, err.Start, err.End, err.Err)
+}
+
+func (err *BatchError) Unwrap() error {
+	return err.Err
+}
+
+// CreateInBatches creates the rows of slice option.GetBatchSize() rows per
+// statement at most, all in one transaction, see BatchError.
+func (repo Repo) CreateInBatches(_db interface{}, slice interface{}, option QuerySelector) (sliceOfResult interface{}, err error) {
+	if sliceOfResult, err = convertToSliceOfStructTypes(slice); err != nil {
+		return nil, err
+	}
+
+	err = createInBatches(_db.(*gorm.DB), sliceOfResult, option)
+	return sliceOfResult, err
+}
+
+// CreateInBatches creates models option.GetBatchSize() rows per statement at
+// most, all in one transaction, see BatchError.
+func (repo TypedRepo[T]) CreateInBatches(db *gorm.DB, models []T, option QuerySelector) ([]T, error) {
+	if len(models) == 0 {
+		return nil, invalidArgument("empty slices")
+	}
+
+	if err := createInBatches(db, &models, option); err != nil {
+		return nil, err
+	}
+	return models, nil
+}
+
+// EachBatch streams the rows of Get in batches of option.GetBatchSize() by
+// primary key order, fn receives each batch as a slice of the model type.
+// Returning an error from fn stops the iteration.
+func (repo Repo) EachBatch(_db interface{}, sliceOfIDs interface{}, option QuerySelector, fn func(batch interface{}) error) error {
+	ptrSliceT, err := newPtrToSliceOf(repo.Model)
+	if err != nil {
+		return err
+	}
+
+	return findInBatches(_db.(*gorm.DB), repo.Model, sliceOfIDs, ptrSliceT, option, func() error {
+		return fn(reflect.ValueOf(ptrSliceT).Elem().Interface())
+	})
+}
+
+// EachBatch streams the rows of Get in batches of option.GetBatchSize() by
+// primary key order. Returning an error from fn stops the iteration.
+func (repo TypedRepo[T]) EachBatch(db *gorm.DB, sliceOfIDs interface{}, option QuerySelector, fn func(batch []T) error) error {
+	var batch []T
+	return findInBatches(db, new(T), sliceOfIDs, &batch, option, func() error {
+		return fn(batch)
+	})
+}
+
+func findInBatches(db *gorm.DB, model interface{}, sliceOfIDs interface{}, ptrToSlice interface{}, option QuerySelector, fn func() error) error {
+	if len(option.GetSorts()) > 0 || option.GetPagination() != nil {
+		return invalidArgument("batched reads are ordered by primary key, sorts and pagination are not supported")
+	}
+
+	modelSchema, err := parseSchema(db, model)
+	if err != nil {
+		return err
+	}
+	if modelSchema.PrioritizedPrimaryField == nil {
+		return gorm.ErrPrimaryKeyRequired
+	}
+
+	for _, v := range option.GetPreloadedFields() {
+		if db, err = preload(db, modelSchema, v, option.GetPreloadSorts()[v]); err != nil {
+			return err
+		}
+	}
+
+	if db, err = whereKeysAndFilters(db, modelSchema, sliceOfIDs, option); err != nil {
+		return err
+	}
+
+	// The primary key is required to seek the next batch
+	selectedFields := option.GetSelectedFields()
+	if len(selectedFields) > 0 {
+		primaryKey := modelSchema.PrioritizedPrimaryField
+		hasPrimaryKey := false
+		for _, name := range selectedFields {
+			hasPrimaryKey = hasPrimaryKey || name == primaryKey.Name || name == primaryKey.DBName
+		}
+		if !hasPrimaryKey {
+			selectedFields = append(append([]string{}, selectedFields...), primaryKey.DBName)
+		}
+	}
+
+	batchSize := option.GetBatchSize()
+	if batchSize <= 0 {
+		batchSize = defaultBatchSize
+	}
+
+	return db.Model(model).
+		Select(selectedFields).
+		Omit(option.GetOmittedFields()...).
+		FindInBatches(ptrToSlice, batchSize, func(tx *gorm.DB, batch int) error {
+			return fn()
+		}).Error
+}
+
+func createInBatches(db *gorm.DB, slice interface{}, option QuerySelector) error {
+	return inChunks(db, slice, option, func(tx *gorm.DB, chunk interface{}) error {
+		return tx.Select(option.GetSelectedFields()).
+			Omit(option.GetOmittedFields()...).
+			Create(chunk).Error
+	})
+}
+
+// inChunks writes the rows of slice by chunks of option.GetBatchSize() rows,
+// fewer when the dialect would not take as many placeholders in a statement.
+// A slice written in more than one chunk is so in a transaction, the error of
+// a chunk is reported as a BatchError.
+func inChunks(db *gorm.DB, slice interface{}, option QuerySelector, write func(tx *gorm.DB, chunk interface{}) error) error {
+	modelSchema, err := parseSchema(db, slice)
+	if err != nil {
+		return err
+	}
+
+	rows := reflect.Indirect(reflect.ValueOf(slice))
+	size := chunkSize(db, modelSchema, option)
+	if rows.Len() <= size {
+		return write(db, slice)
+	}
+
+	return db.Transaction(func(tx *gorm.DB) error {
+		for chunk, start := 0, 0; start < rows.Len(); chunk, start = chunk+1, start+size {
+			end := start + size
+			if end > rows.Len() {
+				end = rows.Len()
+			}
+
+			// The chunk shares the array of slice, which gets the values
+			// created back i.e: the auto increment IDs
+			ptrToChunk := reflect.New(rows.Type())
+			ptrToChunk.Elem().Set(rows.Slice(start, end))
+			if err := write(tx, ptrToChunk.Interface()); err != nil {
+				return &BatchError{Chunk: chunk, Start: start, End: end, Err: err}
+			}
+		}
+		return nil
+	})
+}
+
+// chunkSize is the number of rows of modelSchema a statement inserts at most.
+func chunkSize(db *gorm.DB, modelSchema *schema.Schema, option QuerySelector) int {
+	columns := 0
+	for _, field := range modelSchema.Fields {
+		if field.DBName != "" && field.Creatable {
+			columns++
+		}
+	}
+	if columns == 0 {
+		columns = 1
+	}
+
+	size := maxPlaceholders(db) / columns
+	if batchSize := option.GetBatchSize(); batchSize > 0 && batchSize < size {
+		size = batchSize
+	}
+	return size
+}
+
+// maxPlaceholders is the number of placeholders the dialect of db takes in a
+// statement.
+func maxPlaceholders(db *gorm.DB) int {
+	switch Dialect(db.Dialector.Name()) {
+	case DialectSQLServer:
+		return 2100
+	case DialectSQLite:
+		return 32766
+	}
+	return 65535
+}
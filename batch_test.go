@@ -0,0 +1,198 @@
+package pingorm
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+)
+
+func TestEachBatch(t *testing.T) {
+
+	seeds := []interface{}{
+		&Author{ID: 1, Name: "Henglong"},
+		&Editor{ID: 1, Name: "Vichheka"},
+		&Book{ID: 1, AuthorID: 1, EditorID: 1, Title: "A"},
+		&Book{ID: 2, AuthorID: 1, EditorID: 1, Title: "B"},
+		&Book{ID: 3, AuthorID: 1, EditorID: 1, Title: "C"},
+		&Book{ID: 4, AuthorID: 1, EditorID: 1, Title: "D"},
+		&Book{ID: 5, AuthorID: 1, EditorID: 1, Title: "E"},
+	}
+
+	tests := []struct {
+		inputIDs    interface{}
+		queryParams QueryOption
+		stopAfter   int
+		expBatches  [][]Book
+		expErr      error
+	}{
+		// It should stream the selected fields in batches of 2
+		{
+			queryParams: QueryOption{SelectedFields: []string{"Title"}, BatchSize: 2},
+			expBatches: [][]Book{
+				{{ID: 1, Title: "A"}, {ID: 2, Title: "B"}},
+				{{ID: 3, Title: "C"}, {ID: 4, Title: "D"}},
+				{{ID: 5, Title: "E"}},
+			},
+		},
+		// It should stream the filtered rows only
+		{
+			inputIDs:    []uint32{1, 2, 3, 4},
+			queryParams: QueryOption{SelectedFields: []string{"ID", "Title"}, Filters: []Filter{Gt("ID", 1)}, BatchSize: 2},
+			expBatches: [][]Book{
+				{{ID: 2, Title: "B"}, {ID: 3, Title: "C"}},
+				{{ID: 4, Title: "D"}},
+			},
+		},
+		// It should stop at the error of the callback
+		{
+			queryParams: QueryOption{SelectedFields: []string{"ID", "Title"}, BatchSize: 2},
+			stopAfter:   1,
+			expBatches: [][]Book{
+				{{ID: 1, Title: "A"}, {ID: 2, Title: "B"}},
+			},
+			expErr: errors.New("stop"),
+		},
+		{
+			queryParams: QueryOption{Sorts: []Sort{{Field: "Title"}}},
+			expErr:      &InvalidArgumentError{Reason: "batched reads are ordered by primary key, sorts and pagination are not supported"},
+		},
+	}
+
+	for _, tc := range tests {
+		func() {
+			req := require.New(t)
+
+			cleanTables()
+
+			db, err := OpenDb(dbConString)
+			req.Nil(err)
+			db = db.Debug()
+
+			for _, seed := range seeds {
+				err = db.Create(seed).Error
+				req.Nil(err)
+			}
+
+			var batches [][]Book
+			errEach := TypedRepo[Book]{}.EachBatch(db, tc.inputIDs, tc.queryParams, func(batch []Book) error {
+				batches = append(batches, append([]Book{}, batch...))
+				if tc.stopAfter > 0 && len(batches) == tc.stopAfter {
+					return errors.New("stop")
+				}
+				return nil
+			})
+			req.Equal(tc.expErr, errEach)
+			req.Equal(tc.expBatches, batches)
+		}()
+	}
+}
+
+func TestRepoEachBatchPreload(t *testing.T) {
+	req := require.New(t)
+
+	cleanTables()
+
+	db, err := OpenDb(dbConString)
+	req.Nil(err)
+	db = db.Debug()
+
+	seeds := []interface{}{
+		&Author{ID: 1, Name: "Henglong"},
+		&Author{ID: 2, Name: "Vicheka"},
+		&Editor{ID: 1, Name: "Vichheka"},
+		&Book{ID: 1, AuthorID: 1, EditorID: 1, Title: "A"},
+		&Book{ID: 2, AuthorID: 2, EditorID: 1, Title: "B"},
+	}
+	for _, seed := range seeds {
+		err = db.Create(seed).Error
+		req.Nil(err)
+	}
+
+	var titles []string
+	err = Repo{Model: &Author{}}.EachBatch(db, nil, QueryOption{PreloadedFields: []string{"Books"}, BatchSize: 1}, func(batch interface{}) error {
+		authors := batch.([]Author)
+		req.Len(authors, 1)
+		for _, book := range authors[0].Books {
+			titles = append(titles, book.Title)
+		}
+		return nil
+	})
+	req.Nil(err)
+	req.Equal([]string{"A", "B"}, titles)
+}
+
+func TestCreateInBatches(t *testing.T) {
+	input := []Author{
+		{ID: 1, Name: "Henglong"},
+		{ID: 2, Name: "Vicheka"},
+		{ID: 3, Name: "Vichheka"},
+		{ID: 4, Name: "Dara"},
+		{ID: 5, Name: "Sok"},
+	}
+
+	tests := []struct {
+		seeds      []Author
+		write      func(db *gorm.DB) error
+		expErr     *BatchError
+		expAuthors []Author
+	}{
+		// It should create all the chunks
+		{
+			write: func(db *gorm.DB) error {
+				_, err := TypedRepo[Author]{}.CreateInBatches(db, append([]Author{}, input...), QueryOption{BatchSize: 2})
+				return err
+			},
+			expAuthors: input,
+		},
+		// It should roll back the chunks created before the one failing
+		{
+			seeds: []Author{{ID: 4, Name: "Dara"}},
+			write: func(db *gorm.DB) error {
+				_, err := TypedRepo[Author]{}.CreateInBatches(db, append([]Author{}, input...), QueryOption{BatchSize: 2})
+				return err
+			},
+			expErr:     &BatchError{Chunk: 1, Start: 2, End: 4},
+			expAuthors: []Author{{ID: 4, Name: "Dara"}},
+		},
+		{
+			seeds: []Author{{ID: 1, Name: "Old"}, {ID: 5, Name: "Old"}},
+			write: func(db *gorm.DB) error {
+				_, err := Repo{}.Upsert(db, append([]Author{}, input...), QueryOption{SelectedFields: []string{"Name"}, BatchSize: 2})
+				return err
+			},
+			expAuthors: input,
+		},
+	}
+
+	for _, tc := range tests {
+		func() {
+			req := require.New(t)
+
+			cleanTables()
+
+			db, err := OpenDb(dbConString)
+			req.Nil(err)
+
+			for _, seed := range tc.seeds {
+				req.Nil(db.Create(&seed).Error)
+			}
+
+			err = tc.write(db)
+			if tc.expErr != nil {
+				var batchErr *BatchError
+				req.ErrorAs(err, &batchErr)
+				req.Equal(tc.expErr.Chunk, batchErr.Chunk)
+				req.Equal(tc.expErr.Start, batchErr.Start)
+				req.Equal(tc.expErr.End, batchErr.End)
+			} else {
+				req.Nil(err)
+			}
+
+			var authors []Author
+			req.Nil(db.Select("ID", "Name").Order("id").Find(&authors).Error)
+			req.Equal(tc.expAuthors, authors)
+		}()
+	}
+}
@@ -1,6 +1,8 @@
 package pingorm
 
 import (
+	"context"
+
 	"gorm.io/gorm"
 )
 
@@ -12,40 +14,113 @@ type AuditOption struct {
 	Skip          bool
 }
 
+type (
+	AuditIdentity struct {
+		UserID string
+		OrgID  string
+	}
+
+	// AuditIdentityProvider resolves the creator, updater and org of each
+	// statement, ok is false when the statement must not be stamped.
+	AuditIdentityProvider interface {
+		GetAuditIdentity(ctx context.Context) (identity AuditIdentity, ok bool)
+	}
+
+	AuditIdentityFunc func(ctx context.Context) (AuditIdentity, bool)
+)
+
+// Implement AuditIdentityProvider, a plain AuditIdentity stamps the same
+// identity on every statement.
+func (identity AuditIdentity) GetAuditIdentity(context.Context) (AuditIdentity, bool) {
+	return identity, true
+}
+
+func (f AuditIdentityFunc) GetAuditIdentity(ctx context.Context) (AuditIdentity, bool) {
+	return f(ctx)
+}
+
+// RegisterAuditCallbacks stamps uid and orgID on every statement of db unless
+// the statement context carries an identity, see ContextWithAuditIdentity.
+// It is a no-op if the audit callbacks are already registered.
 func RegisterAuditCallbacks(db *gorm.DB, uid string, orgID string) {
-	if createCallback := db.Callback().Create(); createCallback.Get("app:creator") == nil {
-		createCallback.Before("gorm:create").Register("app:creator", func(tx *gorm.DB) {
-
-			var options []AuditOption
-			if opt, has := tx.Get(AuditOptionOnCreateKey); has {
-				if singleOption, ok := opt.(AuditOption); ok {
-					options = append(options, singleOption)
-				}
-				if multiOptions, ok := opt.([]AuditOption); ok {
-					options = multiOptions
-				}
-			}
+	registerAuditCallbacks(db, AuditIdentity{UserID: uid, OrgID: orgID}, false)
+}
+
+// RegisterAuditIdentityProvider registers the audit callbacks resolving the
+// identity per statement from provider, replacing any registered ones.
+func RegisterAuditIdentityProvider(db *gorm.DB, provider AuditIdentityProvider) {
+	registerAuditCallbacks(db, provider, true)
+}
+
+func registerAuditCallbacks(db *gorm.DB, provider AuditIdentityProvider, replace bool) {
+	creator := func(tx *gorm.DB) {
+		identity, ok := resolveAuditIdentity(tx, provider)
+		if !ok {
+			return
+		}
 
-			if _, ok := tx.Statement.Schema.FieldsByDBName["created_by"]; ok {
-				if canSetAuditValue(tx.Statement.Schema.Table, "created_by", options) {
-					tx.Statement.SetColumn("created_by", uid, true)
-				}
+		options := statementAuditOptions(tx, AuditOptionOnCreateKey)
+		options = append(options, auditOptionsFromContext(tx.Statement.Context)...)
+
+		if _, ok := tx.Statement.Schema.FieldsByDBName["created_by"]; ok {
+			if canSetAuditValue(tx.Statement.Schema.Table, "created_by", options) {
+				tx.Statement.SetColumn("created_by", identity.UserID, true)
 			}
-			if _, ok := tx.Statement.Schema.FieldsByDBName["org_id"]; ok {
-				if canSetAuditValue(tx.Statement.Schema.Table, "org_id", options) {
-					tx.Statement.SetColumn("org_id", orgID, true)
-				}
+		}
+		if _, ok := tx.Statement.Schema.FieldsByDBName["org_id"]; ok {
+			if canSetAuditValue(tx.Statement.Schema.Table, "org_id", options) {
+				tx.Statement.SetColumn("org_id", identity.OrgID, true)
 			}
-		})
+		}
+	}
+
+	updater := func(tx *gorm.DB) {
+		identity, ok := resolveAuditIdentity(tx, provider)
+		if !ok {
+			return
+		}
+
+		if _, ok := tx.Statement.Schema.FieldsByDBName["updated_by"]; ok {
+			tx.Statement.SetColumn("updated_by", identity.UserID, true)
+		}
+	}
+
+	if createCallback := db.Callback().Create(); createCallback.Get("app:creator") == nil {
+		createCallback.Before("gorm:create").Register("app:creator", creator)
+	} else if replace {
+		createCallback.Replace("app:creator", creator)
 	}
 
 	if updateCallback := db.Callback().Update(); updateCallback.Get("app:updater") == nil {
-		updateCallback.Before("gorm:update").Register("app:updater", func(tx *gorm.DB) {
-			if _, ok := tx.Statement.Schema.FieldsByDBName["updated_by"]; ok {
-				tx.Statement.SetColumn("updated_by", uid, true)
-			}
-		})
+		updateCallback.Before("gorm:update").Register("app:updater", updater)
+	} else if replace {
+		updateCallback.Replace("app:updater", updater)
+	}
+}
+
+func resolveAuditIdentity(tx *gorm.DB, provider AuditIdentityProvider) (AuditIdentity, bool) {
+	if tx.Statement.Schema == nil {
+		return AuditIdentity{}, false
+	}
+	if identity, ok := auditIdentityFromContext(tx.Statement.Context); ok {
+		return identity, true
+	}
+	return provider.GetAuditIdentity(tx.Statement.Context)
+}
+
+// statementAuditOptions reads the AuditOption or []AuditOption set on tx by
+// key.
+func statementAuditOptions(tx *gorm.DB, key string) []AuditOption {
+	var options []AuditOption
+	if opt, has := tx.Get(key); has {
+		if singleOption, ok := opt.(AuditOption); ok {
+			options = append(options, singleOption)
+		}
+		if multiOptions, ok := opt.([]AuditOption); ok {
+			options = append(options, multiOptions...)
+		}
 	}
+	return options
 }
 
 func canSetAuditValue(targetTable, targetColumn string, options []AuditOption) bool {
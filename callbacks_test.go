@@ -0,0 +1,63 @@
+package pingorm
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+	"gorm.io/gorm/schema"
+)
+
+func TestResolveAuditIdentity(t *testing.T) {
+	tests := []struct {
+		ctx         context.Context
+		provider    AuditIdentityProvider
+		expIdentity AuditIdentity
+		expOk       bool
+	}{
+		// It should fall back to the registered identity
+		{
+			ctx:         context.Background(),
+			provider:    AuditIdentity{UserID: "user01", OrgID: "org01"},
+			expIdentity: AuditIdentity{UserID: "user01", OrgID: "org01"},
+			expOk:       true,
+		},
+		// It should prefer the identity of the statement context
+		{
+			ctx:         ContextWithAuditIdentity(context.Background(), AuditIdentity{UserID: "user02", OrgID: "org02"}),
+			provider:    AuditIdentity{UserID: "user01", OrgID: "org01"},
+			expIdentity: AuditIdentity{UserID: "user02", OrgID: "org02"},
+			expOk:       true,
+		},
+		// It should resolve the identity from the provider per statement
+		{
+			ctx: context.WithValue(context.Background(), contextKey("uid"), "user03"),
+			provider: AuditIdentityFunc(func(ctx context.Context) (AuditIdentity, bool) {
+				uid, ok := ctx.Value(contextKey("uid")).(string)
+				return AuditIdentity{UserID: uid}, ok
+			}),
+			expIdentity: AuditIdentity{UserID: "user03"},
+			expOk:       true,
+		},
+		// It should not stamp when the provider can not resolve any identity
+		{
+			ctx: context.Background(),
+			provider: AuditIdentityFunc(func(ctx context.Context) (AuditIdentity, bool) {
+				return AuditIdentity{}, false
+			}),
+			expIdentity: AuditIdentity{},
+			expOk:       false,
+		},
+	}
+
+	for _, tc := range tests {
+		req := require.New(t)
+
+		tx := &gorm.DB{Statement: &gorm.Statement{Schema: &schema.Schema{}, Context: tc.ctx}}
+		identity, ok := resolveAuditIdentity(tx, tc.provider)
+
+		req.Equal(tc.expOk, ok)
+		req.Equal(tc.expIdentity, identity)
+	}
+}
@@ -0,0 +1,278 @@
+package pingorm
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"gorm.io/gorm/schema"
+)
+
+// CascadeRule is what deleting a row does to the rows of one of its has one
+// or has many associations.
+type CascadeRule string
+
+const (
+	// CascadeDelete deletes the associated rows along, and restores them
+	// along when they were deleted with the row.
+	CascadeDelete CascadeRule = "cascade"
+	// CascadeRestrict fails the delete while associated rows exist.
+	CascadeRestrict CascadeRule = "restrict"
+	// CascadeSetNull sets the foreign key of the associated rows to null.
+	CascadeSetNull CascadeRule = "set null"
+)
+
+var ErrDeleteRestricted = errors.New("delete restricted by associated rows")
+
+type cascade struct {
+	rel  *schema.Relationship
+	rule CascadeRule
+}
+
+// cascadesOf is the cascade rules of the associations of modelSchema, rules
+// overrides by association name the OnDelete constraint of its gorm tag.
+func cascadesOf(modelSchema *schema.Schema, rules map[string]CascadeRule) ([]cascade, error) {
+	var cascades []cascade
+	known := map[string]bool{}
+	for _, rel := range append(append([]*schema.Relationship{}, modelSchema.Relationships.HasOne...), modelSchema.Relationships.HasMany...) {
+		known[rel.Name] = true
+
+		rule, ok := rules[rel.Name]
+		if !ok {
+			rule = constraintRule(rel)
+		}
+		switch rule {
+		case "":
+			continue
+		case CascadeDelete, CascadeRestrict, CascadeSetNull:
+			cascades = append(cascades, cascade{rel: rel, rule: rule})
+		default:
+			return nil, invalidArgument("unknown cascade rule %q of %s.%s", rule, modelSchema.Name, rel.Name)
+		}
+	}
+
+	for name := range rules {
+		if !known[name] {
+			return nil, invalidArgument("%s has no has one or has many association %s", modelSchema.Name, name)
+		}
+	}
+	return cascades, nil
+}
+
+func constraintRule(rel *schema.Relationship) CascadeRule {
+	constraint := rel.ParseConstraint()
+	if constraint == nil {
+		return ""
+	}
+	switch strings.ToUpper(constraint.OnDelete) {
+	case "CASCADE":
+		return CascadeDelete
+	case "RESTRICT", "NO ACTION":
+		return CascadeRestrict
+	case "SET NULL":
+		return CascadeSetNull
+	}
+	return ""
+}
+
+// deleteCascading deletes the rows of query along with the associated rows
+// its cascades apply to, all soft deleted at the same time so that restore
+// can tell them apart.
+func deleteCascading(db *gorm.DB, ptrToModel interface{}, modelSchema *schema.Schema, cascades []cascade, sliceOfIDs interface{}, option QuerySelector) (int64, error) {
+	var rowsAffected int64
+	err := db.Transaction(func(tx *gorm.DB) error {
+		if option.IsHardDelete() {
+			tx = tx.Unscoped()
+		}
+		now := tx.NowFunc()
+		tx = tx.Session(&gorm.Session{NowFunc: func() time.Time { return now }})
+
+		query, err := whereKeys(tx, modelSchema, sliceOfIDs, option)
+		if err != nil {
+			return err
+		}
+		query = query.Model(ptrToModel).Session(&gorm.Session{})
+		if err := checkSafe(tx, query, ptrToModel, option); err != nil {
+			return err
+		}
+
+		rows := reflect.New(reflect.SliceOf(modelSchema.ModelType))
+		if err := query.Find(rows.Interface()).Error; err != nil {
+			return err
+		}
+		if err := deleteAssociated(tx, modelSchema, cascades, rows.Elem()); err != nil {
+			return err
+		}
+
+		result := query.Delete(ptrToModel)
+		rowsAffected = result.RowsAffected
+		return result.Error
+	})
+	return rowsAffected, err
+}
+
+func deleteAssociated(tx *gorm.DB, modelSchema *schema.Schema, cascades []cascade, rows reflect.Value) error {
+	if rows.Len() == 0 {
+		return nil
+	}
+
+	for _, c := range cascades {
+		childSchema := c.rel.FieldSchema
+		childModel := reflect.New(childSchema.ModelType).Interface()
+		query := tx.Model(childModel).Where(associatedExpr(tx, c.rel, rows)).Session(&gorm.Session{})
+
+		switch c.rule {
+		case CascadeRestrict:
+			var count int64
+			if err := query.Count(&count).Error; err != nil {
+				return err
+			}
+			if count > 0 {
+				return fmt.Errorf("%w: %s of %s", ErrDeleteRestricted, c.rel.Name, modelSchema.Name)
+			}
+		case CascadeSetNull:
+			values := map[string]interface{}{}
+			for _, ref := range c.rel.References {
+				values[ref.ForeignKey.DBName] = nil
+			}
+			if err := query.Updates(values).Error; err != nil {
+				return err
+			}
+		case CascadeDelete:
+			childCascades, err := cascadesOf(childSchema, nil)
+			if err != nil {
+				return err
+			}
+			children := reflect.New(reflect.SliceOf(childSchema.ModelType))
+			if err := query.Find(children.Interface()).Error; err != nil {
+				return err
+			}
+			if err := deleteAssociated(tx, childSchema, childCascades, children.Elem()); err != nil {
+				return err
+			}
+			if children.Elem().Len() == 0 {
+				continue
+			}
+			if err := query.Delete(childModel).Error; err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// restoreCascading restores the rows of query along with the associated rows
+// deleted with them by the CascadeDelete rules of cascades.
+func restoreCascading(db *gorm.DB, model interface{}, modelSchema *schema.Schema, field *schema.Field, cascades []cascade, sliceOfIDs interface{}, option QuerySelector) error {
+	return db.Transaction(func(tx *gorm.DB) error {
+		query, err := whereKeys(tx.Unscoped(), modelSchema, sliceOfIDs, option)
+		if err != nil {
+			return err
+		}
+		query = query.Model(model).
+			Where(clause.Neq{Column: clause.Column{Table: clause.CurrentTable, Name: field.DBName}, Value: nil}).
+			Session(&gorm.Session{})
+
+		rows := reflect.New(reflect.SliceOf(modelSchema.ModelType))
+		if err := query.Find(rows.Interface()).Error; err != nil {
+			return err
+		}
+		if err := query.Update(field.DBName, nil).Error; err != nil {
+			return err
+		}
+		return restoreAssociated(tx, field, cascades, rows.Elem())
+	})
+}
+
+func restoreAssociated(tx *gorm.DB, field *schema.Field, cascades []cascade, rows reflect.Value) error {
+	ctx := tx.Statement.Context
+	for _, c := range cascades {
+		if c.rule != CascadeDelete || rows.Len() == 0 {
+			continue
+		}
+		childSchema := c.rel.FieldSchema
+		childField, err := softDeleteField(childSchema)
+		if err != nil {
+			// Hard deleted along, there is nothing to restore
+			continue
+		}
+
+		// Only the rows deleted at the very time their parent was
+		orExprs := make([]clause.Expression, rows.Len())
+		for i := range orExprs {
+			row := reflect.Indirect(rows.Index(i))
+			deleted, _ := field.ValueOf(ctx, row)
+			orExprs[i] = clause.And(
+				associatedExpr(tx, c.rel, row),
+				clause.Eq{Column: clause.Column{Table: clause.CurrentTable, Name: childField.DBName}, Value: deleted},
+			)
+		}
+
+		childModel := reflect.New(childSchema.ModelType).Interface()
+		query := tx.Unscoped().Model(childModel).Where(clause.Or(orExprs...)).Session(&gorm.Session{})
+
+		children := reflect.New(reflect.SliceOf(childSchema.ModelType))
+		if err := query.Find(children.Interface()).Error; err != nil {
+			return err
+		}
+		if children.Elem().Len() == 0 {
+			continue
+		}
+		if err := query.Update(childField.DBName, nil).Error; err != nil {
+			return err
+		}
+
+		childCascades, err := cascadesOf(childSchema, nil)
+		if err != nil {
+			return err
+		}
+		if err := restoreAssociated(tx, childField, childCascades, children.Elem()); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// associatedExpr matches the rows of rel associated with rows, a slice or a
+// single row.
+func associatedExpr(tx *gorm.DB, rel *schema.Relationship, rows reflect.Value) clause.Expression {
+	ctx := tx.Statement.Context
+
+	var keyRefs []*schema.Reference
+	var typeExprs []clause.Expression
+	for _, ref := range rel.References {
+		column := clause.Column{Table: clause.CurrentTable, Name: ref.ForeignKey.DBName}
+		if ref.PrimaryKey == nil {
+			// The type column of a polymorphic association
+			typeExprs = append(typeExprs, clause.Eq{Column: column, Value: ref.PrimaryValue})
+			continue
+		}
+		keyRefs = append(keyRefs, ref)
+	}
+
+	if len(keyRefs) == 1 {
+		ref := keyRefs[0]
+		var values []interface{}
+		eachRow(rows, func(row reflect.Value) {
+			value, _ := ref.PrimaryKey.ValueOf(ctx, row)
+			values = append(values, value)
+		})
+		in := clause.IN{Column: clause.Column{Table: clause.CurrentTable, Name: ref.ForeignKey.DBName}, Values: values}
+		return clause.And(append(typeExprs, in)...)
+	}
+
+	var orExprs []clause.Expression
+	eachRow(rows, func(row reflect.Value) {
+		andExprs := make([]clause.Expression, len(keyRefs))
+		for i, ref := range keyRefs {
+			value, _ := ref.PrimaryKey.ValueOf(ctx, row)
+			andExprs[i] = clause.Eq{Column: clause.Column{Table: clause.CurrentTable, Name: ref.ForeignKey.DBName}, Value: value}
+		}
+		orExprs = append(orExprs, clause.And(andExprs...))
+	})
+	return clause.And(append(typeExprs, clause.Or(orExprs...))...)
+}
@@ -0,0 +1,130 @@
+package pingorm
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+)
+
+type (
+	cascadeAuthor struct {
+		ID      uint32 `gorm:"primaryKey"`
+		Name    string
+		Deleted gorm.DeletedAt
+		Books   []cascadeBook `gorm:"foreignKey:AuthorID;constraint:OnDelete:CASCADE"`
+	}
+	cascadeBook struct {
+		ID       uint32 `gorm:"primaryKey"`
+		Title    string
+		AuthorID *uint32
+		Deleted  gorm.DeletedAt
+		Reviews  []cascadeReview `gorm:"foreignKey:BookID;constraint:OnDelete:CASCADE"`
+	}
+	cascadeReview struct {
+		ID      uint32 `gorm:"primaryKey"`
+		BookID  uint32
+		Deleted gorm.DeletedAt
+	}
+)
+
+func TestCascadeDelete(t *testing.T) {
+	repo := TypedRepo[cascadeAuthor]{}
+	authorID := func(id uint32) *uint32 { return &id }
+
+	tests := []struct {
+		write      func(db *gorm.DB) error
+		expErr     error
+		expBooks   []cascadeBook
+		expReviews []uint32
+	}{
+		// It should soft delete the books and their reviews along by the constraint tag
+		{
+			write: func(db *gorm.DB) error {
+				return repo.Delete(db, []uint32{1}, QueryOption{})
+			},
+			expBooks:   []cascadeBook{{ID: 3, Title: "Book 3", AuthorID: authorID(2)}},
+			expReviews: []uint32{3},
+		},
+		{
+			write: func(db *gorm.DB) error {
+				return repo.Delete(db, []uint32{1}, QueryOption{HardDelete: true})
+			},
+			expBooks:   []cascadeBook{{ID: 3, Title: "Book 3", AuthorID: authorID(2)}},
+			expReviews: []uint32{3},
+		},
+		// It should override the constraint tag by the option
+		{
+			write: func(db *gorm.DB) error {
+				return repo.Delete(db, []uint32{1}, QueryOption{Cascades: map[string]CascadeRule{"Books": CascadeRestrict}})
+			},
+			expErr: ErrDeleteRestricted,
+			expBooks: []cascadeBook{
+				{ID: 1, Title: "Book 1", AuthorID: authorID(1)},
+				{ID: 3, Title: "Book 3", AuthorID: authorID(2)},
+			},
+			expReviews: []uint32{1, 3},
+		},
+		{
+			write: func(db *gorm.DB) error {
+				return repo.Delete(db, []uint32{1}, QueryOption{Cascades: map[string]CascadeRule{"Books": CascadeSetNull}})
+			},
+			expBooks: []cascadeBook{
+				{ID: 1, Title: "Book 1"},
+				{ID: 3, Title: "Book 3", AuthorID: authorID(2)},
+			},
+			expReviews: []uint32{1, 3},
+		},
+		// It should restore the books and reviews deleted along only
+		{
+			write: func(db *gorm.DB) error {
+				if err := repo.Delete(db, []uint32{1}, QueryOption{}); err != nil {
+					return err
+				}
+				return repo.Restore(db, []uint32{1}, QueryOption{})
+			},
+			expBooks: []cascadeBook{
+				{ID: 1, Title: "Book 1", AuthorID: authorID(1)},
+				{ID: 3, Title: "Book 3", AuthorID: authorID(2)},
+			},
+			expReviews: []uint32{1, 3},
+		},
+	}
+
+	for _, tc := range tests {
+		func() {
+			req := require.New(t)
+
+			db := openFixture(req, []interface{}{&cascadeAuthor{}, &cascadeBook{}, &cascadeReview{}}, []cascadeAuthor{
+				{ID: 1, Name: "Henglong", Books: []cascadeBook{
+					{ID: 1, Title: "Book 1", Reviews: []cascadeReview{{ID: 1}, {ID: 2}}},
+					{ID: 2, Title: "Book 2"},
+				}},
+				{ID: 2, Name: "Vicheka", Books: []cascadeBook{
+					{ID: 3, Title: "Book 3", Reviews: []cascadeReview{{ID: 3}}},
+				}},
+			})
+			defer closeDb(db)
+			// Deleted before, it should stay deleted on restore
+			req.Nil(db.Session(&gorm.Session{NowFunc: func() time.Time { return time.Now().Add(-time.Hour) }}).
+				Delete(&cascadeBook{}, 2).Error)
+			req.Nil(db.Delete(&cascadeReview{}, 2).Error)
+
+			err := tc.write(db)
+			if tc.expErr != nil {
+				req.ErrorIs(err, tc.expErr)
+			} else {
+				req.Nil(err)
+			}
+
+			var books []cascadeBook
+			req.Nil(db.Order("id").Find(&books).Error)
+			req.Equal(tc.expBooks, books)
+
+			var reviews []uint32
+			req.Nil(db.Model(&cascadeReview{}).Order("id").Pluck("id", &reviews).Error)
+			req.Equal(tc.expReviews, reviews)
+		}()
+	}
+}
@@ -0,0 +1,60 @@
+package pingorm
+
+import (
+	"context"
+
+	"gorm.io/gorm"
+)
+
+type contextKey string
+
+const (
+	auditOptionContextKey   contextKey = AuditOptionOnCreateKey
+	auditIdentityContextKey contextKey = "app_audit_identity"
+	txHooksContextKey       contextKey = "app_tx_hooks"
+)
+
+// ContextWithAuditOptions returns a copy of ctx carrying options, the audit
+// callbacks read them from the statement context of the *Context repo methods.
+func ContextWithAuditOptions(ctx context.Context, options ...AuditOption) context.Context {
+	return context.WithValue(ctx, auditOptionContextKey, options)
+}
+
+func auditOptionsFromContext(ctx context.Context) []AuditOption {
+	if ctx == nil {
+		return nil
+	}
+	options, _ := ctx.Value(auditOptionContextKey).([]AuditOption)
+	return options
+}
+
+// ContextWithAuditIdentity returns a copy of ctx carrying identity, it takes
+// precedence over the registered AuditIdentityProvider.
+func ContextWithAuditIdentity(ctx context.Context, identity AuditIdentity) context.Context {
+	return context.WithValue(ctx, auditIdentityContextKey, identity)
+}
+
+func auditIdentityFromContext(ctx context.Context) (AuditIdentity, bool) {
+	if ctx == nil {
+		return AuditIdentity{}, false
+	}
+	identity, ok := ctx.Value(auditIdentityContextKey).(AuditIdentity)
+	return identity, ok
+}
+
+// dbWithContext binds ctx to db, it fails fast if ctx is already done.
+func dbWithContext(ctx context.Context, _db interface{}) (*gorm.DB, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	return _db.(*gorm.DB).WithContext(ctx), nil
+}
+
+// contextError reports ctx.Err() in place of the driver error when the query
+// was aborted by ctx, i.e: "invalid connection" after a cancel.
+func contextError(ctx context.Context, err error) error {
+	if err != nil && ctx.Err() != nil {
+		return ctx.Err()
+	}
+	return err
+}
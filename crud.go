@@ -1,13 +1,14 @@
 package pingorm
 
 import (
-	"errors"
+	"context"
 	"fmt"
 	"reflect"
 	"strings"
 
 	"gorm.io/gorm"
 	"gorm.io/gorm/clause"
+	"gorm.io/gorm/schema"
 )
 
 type Repo struct {
@@ -20,13 +21,7 @@ func (repo Repo) Create(_db interface{}, model interface{}, option QuerySelector
 		return nil, err
 	}
 
-	db := _db.(*gorm.DB).
-		Set("value:update_on_conflict", option.GetUpdatesOnConflict())
-
-	err = db.Select(option.GetSelectedFields()).
-		Omit(option.GetOmittedFields()...).
-		Create(ptrToModel).Error
-
+	err = create(_db.(*gorm.DB), ptrToModel, option)
 	return ptrToModel, err
 }
 
@@ -36,10 +31,7 @@ func (repo Repo) Update(_db interface{}, model interface{}, option QuerySelector
 		return nil, err
 	}
 
-	db := _db.(*gorm.DB).Set("value:update_on_conflict", option.GetUpdatesOnConflict())
-
-	db = db.Select(option.GetSelectedFields()).Omit(option.GetOmittedFields()...)
-	err = db.Updates(ptrToModel).Error
+	err = update(_db.(*gorm.DB), ptrToModel, option)
 	return ptrToModel, err
 }
 
@@ -49,115 +41,390 @@ func (repo Repo) Upsert(_db interface{}, slice interface{}, options QuerySelecto
 		return nil, err
 	}
 
-	db := _db.(*gorm.DB)
-	err = db.Clauses(clause.OnConflict{
-		DoUpdates: clause.AssignmentColumns(options.GetSelectedFields()),
-	}).Omit(options.GetOmittedFields()...).Create(sliceOfResult).Error
-
+	err = upsert(_db.(*gorm.DB), sliceOfResult, options)
 	return sliceOfResult, err
 }
 
 func (repo Repo) Delete(_db interface{}, sliceOfIDs interface{}, option QuerySelector) error {
+	_, err := repo.DeleteResult(_db, sliceOfIDs, option)
+	return err
+}
+
+// DeleteResult deletes as Delete does and reports the rows deleted, see
+// QueryOption.Guard.
+func (repo Repo) DeleteResult(_db interface{}, sliceOfIDs interface{}, option QuerySelector) (MutationResult, error) {
 
 	ptrToModel, err := parseModelToPtr(repo.Model)
 	if err != nil {
-		return err
+		return MutationResult{}, err
+	}
+
+	isEmpty, err := isEmptySlice(sliceOfIDs)
+	if err != nil {
+		return MutationResult{}, err
+	}
+
+	return guardMutation(_db.(*gorm.DB), option, isEmpty, func(tx *gorm.DB) (int64, error) {
+		return deleteByKeyList(tx, ptrToModel, sliceOfIDs, option)
+	})
+}
+
+func (repo Repo) Updates(_db interface{}, sliceOfIDs interface{}, values interface{}, option QuerySelector) error {
+	_, err := repo.UpdatesResult(_db, sliceOfIDs, values, option)
+	return err
+}
+
+// UpdatesResult updates as Updates does and reports the rows updated, see
+// QueryOption.Guard.
+func (repo Repo) UpdatesResult(_db interface{}, sliceOfIDs interface{}, values interface{}, option QuerySelector) (MutationResult, error) {
+
+	isEmpty, err := isEmptySlice(sliceOfIDs)
+	if err != nil {
+		return MutationResult{}, err
 	}
 
-	if reflect.TypeOf(sliceOfIDs).Kind() == reflect.Slice {
-		if reflect.ValueOf(sliceOfIDs).Len() == 0 {
-			return nil
+	// The keys default to the primary key of the model, values may carry the
+	// model itself when there is none
+	var ptrToModel interface{}
+	if repo.Model != nil {
+		if ptrToModel, err = parseModelToPtr(repo.Model); err != nil {
+			return MutationResult{}, err
 		}
-	} else {
-		panic("slice required")
 	}
 
+	return guardMutation(_db.(*gorm.DB), option, isEmpty, func(tx *gorm.DB) (int64, error) {
+		return updatesByKeys(tx, ptrToModel, sliceOfIDs, values, option)
+	})
+}
+
+func (repo Repo) Get(_db interface{}, sliceOfIDs interface{}, option QuerySelector) (sliceT interface{}, err error) {
+	sliceT, _, err = repo.GetPage(_db, sliceOfIDs, option)
+	return sliceT, err
+}
+
+// GetPage gets one page of the rows as Get does along with the info of
+// the next page, see QueryOption.Pagination.
+func (repo Repo) GetPage(_db interface{}, sliceOfIDs interface{}, option QuerySelector) (sliceT interface{}, pageInfo PageInfo, err error) {
 	db := _db.(*gorm.DB)
-	if option.IsHardDelete() {
-		db = db.Unscoped()
+
+	// The query error is left unreported as it always was, i.e: preloading
+	// an unsupported relation still gets the rows found.
+	ptrSliceT, err := newPtrToSliceOf(repo.Model)
+	if err != nil {
+		return nil, PageInfo{}, err
+	}
+	keysetColumns, _, err := findByKeyList(db, repo.Model, sliceOfIDs, option, ptrSliceT)
+	if err != nil {
+		return nil, PageInfo{}, err
+	}
+
+	if pageInfo, err = buildPageInfo(db.Statement.Context, ptrSliceT, option.GetPagination(), keysetColumns); err != nil {
+		return nil, PageInfo{}, err
+	}
+
+	sliceT = reflect.ValueOf(ptrSliceT).Elem().Interface()
+	return sliceT, pageInfo, nil
+}
+
+func (repo Repo) CreateContext(ctx context.Context, _db interface{}, model interface{}, option QuerySelector) (interface{}, error) {
+	db, err := dbWithContext(ctx, _db)
+	if err != nil {
+		return nil, err
+	}
+
+	ptrToModel, err := repo.Create(db, model, option)
+	return ptrToModel, contextError(ctx, err)
+}
+
+func (repo Repo) UpdateContext(ctx context.Context, _db interface{}, model interface{}, option QuerySelector) (interface{}, error) {
+	db, err := dbWithContext(ctx, _db)
+	if err != nil {
+		return nil, err
+	}
+
+	ptrToModel, err := repo.Update(db, model, option)
+	return ptrToModel, contextError(ctx, err)
+}
+
+func (repo Repo) UpsertContext(ctx context.Context, _db interface{}, slice interface{}, options QuerySelector) (interface{}, error) {
+	db, err := dbWithContext(ctx, _db)
+	if err != nil {
+		return nil, err
 	}
 
-	whereExpr, whereArgs, err := buildWhereExprByKeys(db, sliceOfIDs, option)
+	sliceOfResult, err := repo.Upsert(db, slice, options)
+	return sliceOfResult, contextError(ctx, err)
+}
+
+func (repo Repo) DeleteContext(ctx context.Context, _db interface{}, sliceOfIDs interface{}, option QuerySelector) error {
+	db, err := dbWithContext(ctx, _db)
 	if err != nil {
 		return err
 	}
 
-	return db.Where(whereExpr, whereArgs).Delete(ptrToModel).Error
+	return contextError(ctx, repo.Delete(db, sliceOfIDs, option))
 }
 
-func (repo Repo) Updates(_db interface{}, sliceOfIDs interface{}, values interface{}, option QuerySelector) error {
+func (repo Repo) UpdatesContext(ctx context.Context, _db interface{}, sliceOfIDs interface{}, values interface{}, option QuerySelector) error {
+	db, err := dbWithContext(ctx, _db)
+	if err != nil {
+		return err
+	}
 
-	if reflect.TypeOf(sliceOfIDs).Kind() == reflect.Slice {
-		if reflect.ValueOf(sliceOfIDs).Len() == 0 {
-			return nil
-		}
-	} else {
-		panic("slice required")
+	return contextError(ctx, repo.Updates(db, sliceOfIDs, values, option))
+}
+
+func (repo Repo) GetContext(ctx context.Context, _db interface{}, sliceOfIDs interface{}, option QuerySelector) (interface{}, error) {
+	db, err := dbWithContext(ctx, _db)
+	if err != nil {
+		return nil, err
 	}
 
-	db := _db.(*gorm.DB)
-	db = db.Select(option.GetSelectedFields()).Omit(append(option.GetOmittedFields(), clause.Associations)...)
+	// Get does not report the query error, a canceled query is reported here
+	sliceT, err := repo.Get(db, sliceOfIDs, option)
+	if err == nil {
+		err = ctx.Err()
+	}
+	return sliceT, err
+}
+
+// newPtrToSliceOf returns a pointer to a slice of the struct type of model
+func newPtrToSliceOf(model interface{}) (interface{}, error) {
+	ptrToModel, err := parseModelToPtr(model)
+	if err != nil {
+		return nil, err
+	}
+	return reflect.New(reflect.SliceOf(reflect.TypeOf(ptrToModel).Elem())).Interface(), nil
+}
 
-	return db.Where("id IN ?", sliceOfIDs).Updates(values).Error
+func create(db *gorm.DB, ptrToModel interface{}, option QuerySelector) error {
+	return db.Set("value:update_on_conflict", option.GetUpdatesOnConflict()).
+		Select(option.GetSelectedFields()).
+		Omit(option.GetOmittedFields()...).
+		Create(ptrToModel).Error
 }
 
-func (repo Repo) Get(_db interface{}, sliceOfIDs interface{}, option QuerySelector) (sliceT interface{}, err error) {
-	var ptrSliceT interface{}
-	db := _db.(*gorm.DB)
+func update(db *gorm.DB, ptrToModel interface{}, option QuerySelector) error {
+	return db.Set("value:update_on_conflict", option.GetUpdatesOnConflict()).
+		Select(option.GetSelectedFields()).
+		Omit(option.GetOmittedFields()...).
+		Updates(ptrToModel).Error
+}
+
+func upsert(db *gorm.DB, slice interface{}, option QuerySelector) error {
+	modelSchema, err := parseSchema(db, slice)
+	if err != nil {
+		return err
+	}
+
+	conflict := onConflict(db, modelSchema, option)
+	return inChunks(db, slice, option, func(tx *gorm.DB, chunk interface{}) error {
+		return tx.Clauses(conflict).Omit(option.GetOmittedFields()...).Create(chunk).Error
+	})
+}
+
+func deleteByKeys(db *gorm.DB, ptrToModel interface{}, sliceOfIDs interface{}, option QuerySelector) (int64, error) {
+	modelSchema, err := parseSchema(db, ptrToModel)
+	if err != nil {
+		return 0, err
+	}
+	cascades, err := cascadesOf(modelSchema, option.GetCascades())
+	if err != nil {
+		return 0, err
+	}
+	if len(cascades) > 0 {
+		return deleteCascading(db, ptrToModel, modelSchema, cascades, sliceOfIDs, option)
+	}
+
+	// A new session keeps the conditions of the query off db, which checkSafe
+	// counts the rows of the table by
+	db = db.Session(&gorm.Session{})
+	if option.IsHardDelete() {
+		db = db.Unscoped().Session(&gorm.Session{})
+	}
+
+	query, err := whereKeys(db, modelSchema, sliceOfIDs, option)
+	if err != nil {
+		return 0, err
+	}
+	if err := checkSafe(db, query, ptrToModel, option); err != nil {
+		return 0, err
+	}
+
+	result := query.Delete(ptrToModel)
+	return result.RowsAffected, result.Error
+}
+
+// updatesByKeys updates the rows of model whose keys are in sliceOfIDs,
+// model may be nil when values carries the model type itself.
+func updatesByKeys(db *gorm.DB, model interface{}, sliceOfIDs interface{}, values interface{}, option QuerySelector) (int64, error) {
+	schemaModel := values
+	if model != nil {
+		schemaModel = model
+	}
+
+	// A map of values without any model is left to gorm to report
+	modelSchema, _ := parseSchema(db, schemaModel)
+
+	// A new session keeps the conditions of the query off db, which checkSafe
+	// counts the rows of the table by
+	db = db.Session(&gorm.Session{})
+	query, err := whereKeys(db, modelSchema, sliceOfIDs, option)
+	if err != nil {
+		return 0, err
+	}
+	if modelSchema != nil {
+		if err := checkSafe(db, query, reflect.New(modelSchema.ModelType).Interface(), option); err != nil {
+			return 0, err
+		}
+	}
+
+	if model != nil {
+		query = query.Model(model)
+	}
+	result := query.Select(option.GetSelectedFields()).
+		Omit(append(option.GetOmittedFields(), clause.Associations)...).
+		Updates(values)
+	return result.RowsAffected, result.Error
+}
+
+// findQuery builds the query of Get, keysetColumns are to be passed on to
+// buildPageInfo along with the rows found.
+func findQuery(db *gorm.DB, model interface{}, sliceOfIDs interface{}, option QuerySelector) (query *gorm.DB, keysetColumns []sortColumn, err error) {
+	modelSchema, err := parseSchema(db, model)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	// A new session keeps the conditions of the query off db, which may be a
+	// chained db shared by the chunks of a key list
+	db = db.Session(&gorm.Session{})
+
 	for _, v := range option.GetPreloadedFields() {
-		db = db.Preload(v)
+		if db, err = preload(db, modelSchema, v, option.GetPreloadSorts()[v]); err != nil {
+			return nil, nil, err
+		}
 	}
 
-	whereExpr, whereArgs, err := buildWhereExprByKeys(db, sliceOfIDs, option)
+	if db, err = whereKeysAndFilters(db, modelSchema, sliceOfIDs, option); err != nil {
+		return nil, nil, err
+	}
+
+	sortColumns, err := resolveSorts(db, modelSchema, option.GetSorts())
 	if err != nil {
+		return nil, nil, err
+	}
+	db = orderBy(db, sortColumns)
+
+	if db, keysetColumns, err = paginate(db, modelSchema, option, sortColumns); err != nil {
+		return nil, nil, err
+	}
+
+	query = db.Model(model).
+		Select(option.GetSelectedFields()).
+		Omit(option.GetOmittedFields()...)
+	return query, keysetColumns, nil
+}
+
+// whereKeysAndFilters scopes db to the rows whose keys are in sliceOfIDs and
+// matching the option filters, a nil sliceOfIDs scopes by the filters only.
+// The soft deleted rows are in or out as per the option.
+func whereKeysAndFilters(db *gorm.DB, modelSchema *schema.Schema, sliceOfIDs interface{}, option QuerySelector) (*gorm.DB, error) {
+	var err error
+	if db, err = scopeDeleted(db, modelSchema, option); err != nil {
 		return nil, err
 	}
 
-	if mt := reflect.TypeOf(repo.Model); mt.Kind() == reflect.Ptr {
-		ptrSliceT = reflect.New(
-			reflect.MakeSlice(reflect.SliceOf(mt.Elem()), 0, 0).Type(),
-		).Interface()
+	if sliceOfIDs != nil {
+		if db, err = whereKeys(db, modelSchema, sliceOfIDs, option); err != nil {
+			return nil, err
+		}
+	}
 
-	} else {
-		ptrSliceT = reflect.New(
-			reflect.MakeSlice(reflect.SliceOf(mt), 0, 0).Type(),
-		).Interface()
+	filterExprs, err := buildFilterExprs(db, modelSchema, option.GetFilters())
+	if err != nil {
+		return nil, err
 	}
+	for _, expr := range filterExprs {
+		db = db.Where(expr)
+	}
+	return db, nil
+}
 
-	db.Model(repo.Model).
-		Select(option.GetSelectedFields()).
-		Omit(option.GetOmittedFields()...).Where(whereExpr, whereArgs).
-		Find(ptrSliceT)
+// whereKeys scopes db to the rows whose keys are in sliceOfIDs, composite
+// keys are expanded to "(colA = ? AND colB = ?) OR ..." for the dialects
+// without row values.
+func whereKeys(db *gorm.DB, modelSchema *schema.Schema, sliceOfIDs interface{}, option QuerySelector) (*gorm.DB, error) {
+	// The keys were loaded into a temporary table by withKeysTable
+	if name, ok := db.Get(keysTableKey); ok {
+		return db.Where(keysTableExpr(name.(string), keyColumns(db, modelSchema, option))), nil
+	}
 
-	sliceT = reflect.ValueOf(ptrSliceT).Elem().Interface()
-	return sliceT, err
+	whereExpr, whereArgs, err := buildWhereExprByKeys(db, modelSchema, sliceOfIDs, option)
+	if err != nil {
+		return nil, err
+	}
+
+	rows, isComposite := whereArgs.([][]interface{})
+	if !isComposite || supportsRowValues(db) {
+		return db.Where(whereExpr, whereArgs), nil
+	}
+
+	if len(rows) == 0 {
+		return db.Where("1 = 0"), nil
+	}
+
+	orExprs := make([]clause.Expression, len(rows))
+	for i, row := range rows {
+		andExprs := make([]clause.Expression, len(row))
+		for j, column := range keyColumns(db, modelSchema, option) {
+			andExprs[j] = clause.Eq{Column: clause.Column{Name: column}, Value: row[j]}
+		}
+		orExprs[i] = clause.And(andExprs...)
+	}
+	return db.Where(clause.Or(orExprs...)), nil
+}
+
+func parseSchema(db *gorm.DB, model interface{}) (*schema.Schema, error) {
+	stmt := &gorm.Statement{DB: db}
+	if err := stmt.Parse(model); err != nil {
+		return nil, err
+	}
+	return stmt.Schema, nil
 }
 
-func buildWhereExprByKeys(db *gorm.DB, sliceOfKeyVals interface{}, option QuerySelector) (string, interface{}, error) {
+// keyColumns are the columns of option.GetKeys(), the primary key columns of
+// modelSchema when there are none. modelSchema may be nil, the key is then
+// expected to be the column id.
+func keyColumns(db *gorm.DB, modelSchema *schema.Schema, option QuerySelector) []string {
 	var keyCols []string
 	for _, key := range option.GetKeys() {
 		keyCols = append(keyCols, db.NamingStrategy.ColumnName("", key))
 	}
+	if len(keyCols) > 0 {
+		return keyCols
+	}
+	if modelSchema != nil && len(modelSchema.PrimaryFieldDBNames) > 0 {
+		return modelSchema.PrimaryFieldDBNames
+	}
+	return []string{"id"}
+}
+
+func buildWhereExprByKeys(db *gorm.DB, modelSchema *schema.Schema, sliceOfKeyVals interface{}, option QuerySelector) (string, interface{}, error) {
+	keyCols := keyColumns(db, modelSchema, option)
 	keyLength := len(keyCols)
 
 	// Reflect the value of first dimension slice in order to iterate through slice
 	sliceValues := reflect.ValueOf(sliceOfKeyVals)
 
-	// Build condition expression of empty or only one field option keys
-	if keyLength <= 1 {
+	// Build condition expression of a single key column
+	// i.e: QueryOption{Keys: []string{"keyA"}}
+	if keyLength == 1 {
 		if err := assertSingleDimenSlice(sliceOfKeyVals); err != nil {
 			return "", nil, err
 		}
 
-		var key string
-		if keyLength == 0 {
-			// Table is expected to have unique key column id if keys are not specify.
-			key = "id"
-		} else {
-			// Build condition expression of option keys with only one field
-			// i.e: QueryOption{Keys: []string{"keyA"}}
-			key = keyCols[0]
-		}
+		key := keyCols[0]
 
 		var argVals []interface{}
 		for sliceIdx := 0; sliceIdx < sliceValues.Len(); sliceIdx++ {
@@ -184,7 +451,7 @@ func buildWhereExprByKeys(db *gorm.DB, sliceOfKeyVals interface{}, option QueryS
 
 		// assert the length of each slice2DValues match the key's length
 		if slice2DVal.Len() != keyLength {
-			return "", nil, fmt.Errorf("key length %v requires value length %v", keyLength, keyLength)
+			return "", nil, invalidArgument("key length %v requires value length %v", keyLength, keyLength)
 		}
 
 		var slices []interface{}
@@ -205,7 +472,7 @@ func assertSingleDimenSlice(sliceOfValues interface{}) error {
 	}
 
 	if argType.Elem().Kind() == reflect.Slice {
-		return errors.New("value must be a single dimension slice")
+		return invalidArgument("value must be a single dimension slice")
 	}
 
 	return nil
@@ -219,11 +486,11 @@ func assert2DimenSlice(sliceOfSlice interface{}) error {
 
 	sliceElemType := argType.Elem()
 	if sliceElemType.Kind() != reflect.Slice {
-		return errors.New("value must be 2 dimension slice")
+		return invalidArgument("value must be 2 dimension slice")
 	}
 
 	if slice2DElemType := sliceElemType.Elem(); slice2DElemType.Kind() == reflect.Slice {
-		return errors.New("value must be 2 dimension slice")
+		return invalidArgument("value must be 2 dimension slice")
 	}
 
 	return nil
@@ -231,14 +498,18 @@ func assert2DimenSlice(sliceOfSlice interface{}) error {
 
 func assertSliceType(valueType reflect.Type) error {
 	if valueType.Kind() != reflect.Slice {
-		return errors.New("value must be a kind of slice")
+		return invalidArgument("value must be a kind of slice")
 	}
 
 	return nil
 }
 
 func parseModelToPtr(model interface{}) (interface{}, error) {
-	if modelType := reflect.TypeOf(model); modelType.Kind() == reflect.Struct {
+	// A nil model has no type, i.e: Repo{} without any Model
+	if modelType := reflect.TypeOf(model); modelType == nil {
+		return nil, invalidArgument("model must be a kind of struct or pointer to struct type")
+
+	} else if modelType.Kind() == reflect.Struct {
 		ptrToModelVal := reflect.New(modelType)
 		ptrToModelVal.Elem().Set(reflect.ValueOf(model))
 		return ptrToModelVal.Interface(), nil
@@ -247,21 +518,21 @@ func parseModelToPtr(model interface{}) (interface{}, error) {
 		return model, nil
 
 	}
-	return nil, errors.New("model must be a kind of struct or pointer to struct type")
+	return nil, invalidArgument("model must be a kind of struct or pointer to struct type")
 }
 
 func convertToSliceOfStructTypes(sliceArg interface{}) (interface{}, error) {
 
 	var sliceVal reflect.Value
 
-	if sliceType := reflect.TypeOf(sliceArg); sliceType.Kind() != reflect.Slice {
-		panic("slice required")
+	if sliceType := reflect.TypeOf(sliceArg); sliceType == nil || sliceType.Kind() != reflect.Slice {
+		return nil, invalidArgument("value must be a kind of slice")
 
 	} else if tk := sliceType.Elem().Kind(); tk != reflect.Interface {
 		return sliceArg, nil
 
 	} else if sliceVal = reflect.ValueOf(sliceArg); sliceVal.Len() == 0 {
-		return nil, errors.New("empty slices")
+		return nil, invalidArgument("empty slices")
 	}
 
 	firstConcreteElement := sliceVal.Index(0).Interface()
@@ -278,7 +549,7 @@ func convertToSliceOfStructTypes(sliceArg interface{}) (interface{}, error) {
 		if isKindOf() {
 			break
 		} else if i == len(supportedKinds)-1 {
-			panic("element is not struct or pointer to struct")
+			return nil, invalidArgument("element is not struct or pointer to struct")
 		}
 	}
 
@@ -290,7 +561,7 @@ func convertToSliceOfStructTypes(sliceArg interface{}) (interface{}, error) {
 			sliceOfValues = reflect.Append(sliceOfValues, val)
 
 		} else {
-			panic("some incompatible slice element types found")
+			return nil, invalidArgument("some incompatible slice element types found")
 		}
 	}
 
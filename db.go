@@ -1,23 +1,71 @@
 package pingorm
 
 import (
+	"strings"
+	"time"
+
 	"gorm.io/driver/mysql"
+	"gorm.io/driver/postgres"
+	"gorm.io/driver/sqlite"
+	"gorm.io/driver/sqlserver"
 	"gorm.io/gorm"
 	"gorm.io/gorm/clause"
 	"gorm.io/gorm/logger"
 	"gorm.io/gorm/schema"
 )
 
+type Dialect string
+
+const (
+	DialectMySQL     Dialect = "mysql"
+	DialectPostgres  Dialect = "postgres"
+	DialectSQLite    Dialect = "sqlite"
+	DialectSQLServer Dialect = "sqlserver"
+)
+
+// DbOption tunes the connection pool and the gorm config of OpenDbOption,
+// zero values keep the defaults of OpenDb.
+type DbOption struct {
+	// Dialect overrides the dialect parsed from the scheme of the DSN
+	Dialect Dialect
+
+	MaxOpenConns    int
+	MaxIdleConns    int
+	ConnMaxLifetime time.Duration
+	ConnMaxIdleTime time.Duration
+
+	// ConnectRetries retries to open the database until it is reachable,
+	// waiting ConnectBackoff doubled on each retry.
+	ConnectRetries int
+	ConnectBackoff time.Duration
+
+	Logger         logger.Interface
+	NamingStrategy schema.Namer
+	PrepareStmt    bool
+
+	// Replicas serve the reads made outside transactions, picked by
+	// ReplicaPolicy among the replicas passing their health check.
+	Replicas                   []Replica
+	ReplicaPolicy              ReplicaPolicy
+	ReplicaHealthCheckInterval time.Duration
+}
+
+const defaultConnectBackoff = time.Second
+
+// OpenDb opens conString with the dialect of its scheme i.e: "postgres://",
+// "sqlite://file.db" or "sqlserver://", it is a MySQL DSN without any scheme.
 func OpenDb(conString string) (*gorm.DB, error) {
+	return OpenDbOption(conString, DbOption{})
+}
 
-	var err error
-	var db *gorm.DB
+func OpenDbDialect(dialect Dialect, dsn string) (*gorm.DB, error) {
+	return OpenDbOption(dsn, DbOption{Dialect: dialect})
+}
 
-	//open connection
-	if db, err = gorm.Open(mysql.Open(conString), &gorm.Config{
-		NamingStrategy: schema.NamingStrategy{SingularTable: true},
-		Logger:         logger.Default,
-	}); err != nil {
+func OpenDbOption(conString string, option DbOption) (*gorm.DB, error) {
+
+	db, err := openDb(conString, option)
+	if err != nil {
 		return nil, err
 	}
 
@@ -33,7 +81,9 @@ func OpenDb(conString string) (*gorm.DB, error) {
 						columnsToUpdate[i] = tx.NamingStrategy.ColumnName("", fieldsToUpdate[i])
 					}
 
+					// Postgres, SQLite and SQL Server require the conflict target
 					tx.Clauses(clause.OnConflict{
+						Columns:   primaryKeyColumns(tx.Statement.Schema),
 						DoUpdates: clause.AssignmentColumns(columnsToUpdate),
 					})
 				}
@@ -41,8 +91,155 @@ func OpenDb(conString string) (*gorm.DB, error) {
 		}
 
 	}); err != nil {
+		closeDb(db)
+		return nil, err
+	}
+
+	if err := db.Callback().Update().Before("gorm:update").Register("app:optimistic_lock", lockVersion); err != nil {
+		closeDb(db)
+		return nil, err
+	}
+	if err := db.Callback().Update().Before("gorm:after_update").Register("app:optimistic_lock_check", checkVersion); err != nil {
+		closeDb(db)
+		return nil, err
+	}
+
+	if err := registerErrorTranslation(db); err != nil {
+		closeDb(db)
 		return nil, err
 	}
 
+	if len(option.Replicas) > 0 {
+		replicas := newReplicaSet(option)
+		if err := db.Use(replicas); err != nil {
+			replicas.close()
+			closeDb(db)
+			return nil, err
+		}
+	}
+
 	return db, nil
 }
+
+// openDb opens conString with the connection settings of option.
+func openDb(conString string, option DbOption) (*gorm.DB, error) {
+
+	var err error
+	var db *gorm.DB
+
+	dialect, dsn := option.Dialect, conString
+	if dialect == "" {
+		dialect, dsn = parseDialect(conString)
+	}
+
+	dialector, err := newDialector(dialect, dsn)
+	if err != nil {
+		return nil, err
+	}
+
+	var namingStrategy schema.Namer = schema.NamingStrategy{SingularTable: true}
+	if option.NamingStrategy != nil {
+		namingStrategy = option.NamingStrategy
+	}
+	dbLogger := logger.Default
+	if option.Logger != nil {
+		dbLogger = option.Logger
+	}
+
+	backoff := option.ConnectBackoff
+	if backoff <= 0 {
+		backoff = defaultConnectBackoff
+	}
+
+	//open connection
+	for attempt := 0; ; attempt++ {
+		if db, err = gorm.Open(dialector, &gorm.Config{
+			NamingStrategy: namingStrategy,
+			Logger:         dbLogger,
+			PrepareStmt:    option.PrepareStmt,
+		}); err == nil {
+			break
+		}
+		closeDb(db)
+		if attempt >= option.ConnectRetries {
+			return nil, err
+		}
+		time.Sleep(backoff << attempt)
+	}
+
+	sqlDB, err := db.DB()
+	if err != nil {
+		return nil, err
+	}
+	if option.MaxOpenConns > 0 {
+		sqlDB.SetMaxOpenConns(option.MaxOpenConns)
+	}
+	if option.MaxIdleConns > 0 {
+		sqlDB.SetMaxIdleConns(option.MaxIdleConns)
+	}
+	if option.ConnMaxLifetime > 0 {
+		sqlDB.SetConnMaxLifetime(option.ConnMaxLifetime)
+	}
+	if option.ConnMaxIdleTime > 0 {
+		sqlDB.SetConnMaxIdleTime(option.ConnMaxIdleTime)
+	}
+
+	return db, nil
+}
+
+func closeDb(db *gorm.DB) {
+	if db == nil || db.Config == nil || db.ConnPool == nil {
+		return
+	}
+	if replicas, ok := db.Plugins[replicasPluginName].(*replicaSet); ok {
+		replicas.close()
+	}
+	if sqlDB, err := db.DB(); err == nil {
+		sqlDB.Close()
+	}
+}
+
+func parseDialect(conString string) (Dialect, string) {
+	switch {
+	case strings.HasPrefix(conString, "postgres://"), strings.HasPrefix(conString, "postgresql://"):
+		return DialectPostgres, conString
+	case strings.HasPrefix(conString, "sqlserver://"):
+		return DialectSQLServer, conString
+	case strings.HasPrefix(conString, "sqlite://"):
+		return DialectSQLite, strings.TrimPrefix(conString, "sqlite://")
+	default:
+		return DialectMySQL, strings.TrimPrefix(conString, "mysql://")
+	}
+}
+
+func newDialector(dialect Dialect, dsn string) (gorm.Dialector, error) {
+	switch dialect {
+	case DialectMySQL:
+		return mysql.Open(dsn), nil
+	case DialectPostgres:
+		return postgres.Open(dsn), nil
+	case DialectSQLite:
+		return sqlite.Open(dsn), nil
+	case DialectSQLServer:
+		return sqlserver.Open(dsn), nil
+	}
+	return nil, invalidArgument("unsupported dialect %s", dialect)
+}
+
+func primaryKeyColumns(modelSchema *schema.Schema) []clause.Column {
+	columns := make([]clause.Column, len(modelSchema.PrimaryFieldDBNames))
+	for i, name := range modelSchema.PrimaryFieldDBNames {
+		columns[i] = clause.Column{Name: name}
+	}
+	return columns
+}
+
+// supportsRowValues reports whether the dialect of db can compare row values
+// i.e: "(colA, colB) IN ((?, ?), (?, ?))"
+func supportsRowValues(db *gorm.DB) bool {
+	switch Dialect(db.Dialector.Name()) {
+	case DialectSQLite, DialectSQLServer:
+		return false
+	}
+	return true
+}
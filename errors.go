@@ -0,0 +1,181 @@
+package pingorm
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	mssql "github.com/denisenkom/go-mssqldb"
+	"github.com/go-sql-driver/mysql"
+	"github.com/jackc/pgconn"
+	"github.com/mattn/go-sqlite3"
+	"gorm.io/gorm"
+)
+
+var (
+	ErrInvalidArgument     = errors.New("invalid argument")
+	ErrNotFound            = errors.New("not found")
+	ErrDuplicateKey        = errors.New("duplicate key")
+	ErrForeignKeyViolation = errors.New("foreign key violation")
+	ErrDeadlock            = errors.New("deadlock")
+)
+
+// InvalidArgumentError reports an argument or an option Repo can not take,
+// it is ErrInvalidArgument to errors.Is.
+type InvalidArgumentError struct {
+	Reason string
+}
+
+func (err *InvalidArgumentError) Error() string {
+	return err.Reason
+}
+
+func (err *InvalidArgumentError) Is(target error) bool {
+	return target == ErrInvalidArgument
+}
+
+func invalidArgument(format string, args ...interface{}) error {
+	return &InvalidArgumentError{Reason: fmt.Sprintf(format, args...)}
+}
+
+// MissingKeysError reports the keys GetByKeys found no row for with
+// QueryOption.StrictKeys, it is ErrNotFound to errors.Is.
+type MissingKeysError struct {
+	Keys []interface{}
+}
+
+func (err *MissingKeysError) Error() string {
+	return fmt.Sprintf("not found: keys %v", err.Keys)
+}
+
+func (err *MissingKeysError) Is(target error) bool {
+	return target == ErrNotFound
+}
+
+// DbError is a driver error translated to ErrNotFound, ErrDuplicateKey,
+// ErrForeignKeyViolation or ErrDeadlock, which it is to errors.Is. Constraint
+// names the unique key or foreign key violated when the driver tells it, the
+// driver error is left to errors.As.
+type DbError struct {
+	Kind       error
+	Constraint string
+	Err        error
+}
+
+func (err *DbError) Error() string {
+	return err.Err.Error()
+}
+
+func (err *DbError) Is(target error) bool {
+	return target == err.Kind
+}
+
+func (err *DbError) Unwrap() error {
+	return err.Err
+}
+
+// registerErrorTranslation registers translateError at the end of every
+// callback chain of db, past the rollback of the transaction of a write.
+func registerErrorTranslation(db *gorm.DB) error {
+	callback := db.Callback()
+	for _, err := range []error{
+		callback.Create().After("gorm:commit_or_rollback_transaction").Register("app:translate_error", translateError),
+		callback.Update().After("gorm:commit_or_rollback_transaction").Register("app:translate_error", translateError),
+		callback.Delete().After("gorm:commit_or_rollback_transaction").Register("app:translate_error", translateError),
+		callback.Query().After("gorm:after_query").Register("app:translate_error", translateError),
+		callback.Row().After("gorm:row").Register("app:translate_error", translateError),
+		callback.Raw().After("gorm:raw").Register("app:translate_error", translateError),
+	} {
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// translateError is registered last on every callback chain so that the
+// errors of the statements come back as DbError.
+func translateError(tx *gorm.DB) {
+	if tx.Error != nil {
+		tx.Error = translateDbError(tx.Error)
+	}
+}
+
+// translateDbError translates err when it is one of the driver errors of a
+// DbError, err is returned as it is otherwise.
+func translateDbError(err error) error {
+	var dbErr *DbError
+	if err == nil || errors.As(err, &dbErr) {
+		return err
+	}
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return &DbError{Kind: ErrNotFound, Err: err}
+	}
+
+	var (
+		mysqlErr  *mysql.MySQLError
+		pgErr     *pgconn.PgError
+		sqliteErr sqlite3.Error
+		mssqlErr  mssql.Error
+	)
+	switch {
+	case errors.As(err, &mysqlErr):
+		switch mysqlErr.Number {
+		case 1062:
+			// Duplicate entry '1' for key 'author.PRIMARY'
+			return &DbError{Kind: ErrDuplicateKey, Constraint: quoted(mysqlErr.Message, "for key '", "'"), Err: err}
+		case 1451, 1452:
+			return &DbError{Kind: ErrForeignKeyViolation, Constraint: quoted(mysqlErr.Message, "CONSTRAINT `", "`"), Err: err}
+		case 1213:
+			return &DbError{Kind: ErrDeadlock, Err: err}
+		}
+	case errors.As(err, &pgErr):
+		switch pgErr.Code {
+		case "23505":
+			return &DbError{Kind: ErrDuplicateKey, Constraint: pgErr.ConstraintName, Err: err}
+		case "23503":
+			return &DbError{Kind: ErrForeignKeyViolation, Constraint: pgErr.ConstraintName, Err: err}
+		case "40P01":
+			return &DbError{Kind: ErrDeadlock, Err: err}
+		}
+	case errors.As(err, &sqliteErr):
+		switch sqliteErr.ExtendedCode {
+		case sqlite3.ErrConstraintUnique, sqlite3.ErrConstraintPrimaryKey:
+			// UNIQUE constraint failed: author.id, SQLite names the columns only
+			return &DbError{Kind: ErrDuplicateKey, Constraint: quoted(sqliteErr.Error(), "failed: ", ""), Err: err}
+		case sqlite3.ErrConstraintForeignKey:
+			return &DbError{Kind: ErrForeignKeyViolation, Err: err}
+		}
+	case errors.As(err, &mssqlErr):
+		switch mssqlErr.Number {
+		case 2627:
+			// Violation of PRIMARY KEY constraint 'PK_author'.
+			return &DbError{Kind: ErrDuplicateKey, Constraint: quoted(mssqlErr.Message, "constraint '", "'"), Err: err}
+		case 2601:
+			// Cannot insert duplicate key row in object 'dbo.author' with unique index 'idx_author_name'.
+			return &DbError{Kind: ErrDuplicateKey, Constraint: quoted(mssqlErr.Message, "unique index '", "'"), Err: err}
+		case 547:
+			return &DbError{Kind: ErrForeignKeyViolation, Constraint: quoted(mssqlErr.Message, "FOREIGN KEY constraint \"", "\""), Err: err}
+		case 1205:
+			return &DbError{Kind: ErrDeadlock, Err: err}
+		}
+	}
+	return err
+}
+
+// quoted is the text of message between prefix and suffix, up to the end of
+// message for an empty suffix.
+func quoted(message, prefix, suffix string) string {
+	start := strings.Index(message, prefix)
+	if start < 0 {
+		return ""
+	}
+	text := message[start+len(prefix):]
+	if suffix == "" {
+		return text
+	}
+	if end := strings.Index(text, suffix); end >= 0 {
+		return text[:end]
+	}
+	return ""
+}
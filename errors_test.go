@@ -0,0 +1,185 @@
+package pingorm
+
+import (
+	"errors"
+	"testing"
+
+	mssql "github.com/denisenkom/go-mssqldb"
+	"github.com/go-sql-driver/mysql"
+	"github.com/jackc/pgconn"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+)
+
+func TestTranslateDbError(t *testing.T) {
+	tests := []struct {
+		input         error
+		expKind       error
+		expConstraint string
+	}{
+		{
+			input:         &mysql.MySQLError{Number: 1062, Message: "Duplicate entry '1' for key 'author.PRIMARY'"},
+			expKind:       ErrDuplicateKey,
+			expConstraint: "author.PRIMARY",
+		},
+		{
+			input:         &mysql.MySQLError{Number: 1452, Message: "Cannot add or update a child row: a foreign key constraint fails (`pingorm`.`book`, CONSTRAINT `fk_author_books` FOREIGN KEY (`author_id`) REFERENCES `author` (`id`))"},
+			expKind:       ErrForeignKeyViolation,
+			expConstraint: "fk_author_books",
+		},
+		{
+			input:   &mysql.MySQLError{Number: 1213, Message: "Deadlock found when trying to get lock"},
+			expKind: ErrDeadlock,
+		},
+		{
+			input:         &pgconn.PgError{Code: "23505", ConstraintName: "author_pkey"},
+			expKind:       ErrDuplicateKey,
+			expConstraint: "author_pkey",
+		},
+		{
+			input:         &pgconn.PgError{Code: "23503", ConstraintName: "fk_author_books"},
+			expKind:       ErrForeignKeyViolation,
+			expConstraint: "fk_author_books",
+		},
+		{
+			input:   &pgconn.PgError{Code: "40P01"},
+			expKind: ErrDeadlock,
+		},
+		{
+			input:         mssql.Error{Number: 2601, Message: "Cannot insert duplicate key row in object 'dbo.author' with unique index 'idx_author_name'."},
+			expKind:       ErrDuplicateKey,
+			expConstraint: "idx_author_name",
+		},
+		{
+			input:         mssql.Error{Number: 547, Message: `The INSERT statement conflicted with the FOREIGN KEY constraint "fk_author_books".`},
+			expKind:       ErrForeignKeyViolation,
+			expConstraint: "fk_author_books",
+		},
+		{
+			input:   gorm.ErrRecordNotFound,
+			expKind: ErrNotFound,
+		},
+		// It should leave the other errors as they are
+		{
+			input: errors.New("failed"),
+		},
+	}
+
+	for _, tc := range tests {
+		func() {
+			req := require.New(t)
+
+			err := translateDbError(tc.input)
+			if tc.expKind == nil {
+				req.Equal(tc.input, err)
+				return
+			}
+
+			req.ErrorIs(err, tc.expKind)
+			var dbErr *DbError
+			req.ErrorAs(err, &dbErr)
+			req.Equal(tc.expConstraint, dbErr.Constraint)
+			req.Equal(tc.input, dbErr.Err)
+		}()
+	}
+}
+
+func TestRepoErrors(t *testing.T) {
+	tests := []struct {
+		write         func(db *gorm.DB) error
+		expKind       error
+		expConstraint string
+	}{
+		// It should return an error rather than panic
+		{
+			write: func(db *gorm.DB) error {
+				return Repo{Model: &Author{}}.Delete(db, uint32(1), QueryOption{})
+			},
+			expKind: ErrInvalidArgument,
+		},
+		{
+			write: func(db *gorm.DB) error {
+				return Repo{Model: &Author{}}.Updates(db, uint32(1), map[string]interface{}{"name": "Vicheka"}, QueryOption{})
+			},
+			expKind: ErrInvalidArgument,
+		},
+		{
+			write: func(db *gorm.DB) error {
+				_, err := Repo{}.Upsert(db, []interface{}{Author{Name: "Henglong"}, Book{Title: "Book 1"}}, QueryOption{})
+				return err
+			},
+			expKind: ErrInvalidArgument,
+		},
+		{
+			write: func(db *gorm.DB) error {
+				_, _, err := TypedRepo[Author]{}.UpsertReport(db, nil, QueryOption{})
+				return err
+			},
+			expKind: ErrInvalidArgument,
+		},
+		// It should report a repo without any model as an invalid argument
+		{
+			write: func(db *gorm.DB) error {
+				return Repo{}.Delete(db, []uint32{1}, QueryOption{})
+			},
+			expKind: ErrInvalidArgument,
+		},
+		{
+			write: func(db *gorm.DB) error {
+				_, err := Repo{}.Get(db, []uint32{1}, QueryOption{})
+				return err
+			},
+			expKind: ErrInvalidArgument,
+		},
+		{
+			write: func(db *gorm.DB) error {
+				_, err := Repo{}.Create(db, nil, QueryOption{})
+				return err
+			},
+			expKind: ErrInvalidArgument,
+		},
+		{
+			write: func(db *gorm.DB) error {
+				_, _, err := TypedRepo[Author]{}.GetPage(db, nil, QueryOption{Pagination: &Pagination{Limit: 1, Keyset: true, Cursor: "not a cursor"}})
+				return err
+			},
+			expKind: ErrInvalidArgument,
+		},
+		{
+			write: func(db *gorm.DB) error {
+				_, err := OpenDbOption(dbConString, DbOption{Dialect: "oracle"})
+				return err
+			},
+			expKind: ErrInvalidArgument,
+		},
+		// It should translate the driver error
+		{
+			write: func(db *gorm.DB) error {
+				_, err := Repo{}.Create(db, Author{ID: 1, Name: "Vicheka"}, QueryOption{})
+				return err
+			},
+			expKind:       ErrDuplicateKey,
+			expConstraint: "author.id",
+		},
+	}
+
+	for _, tc := range tests {
+		func() {
+			req := require.New(t)
+
+			cleanTables()
+
+			db, err := OpenDb(dbConString)
+			req.Nil(err)
+			req.Nil(db.Create(&Author{ID: 1, Name: "Henglong"}).Error)
+
+			err = tc.write(db)
+			req.ErrorIs(err, tc.expKind)
+			if tc.expConstraint != "" && testDialect() == DialectSQLite {
+				var dbErr *DbError
+				req.ErrorAs(err, &dbErr)
+				req.Equal(tc.expConstraint, dbErr.Constraint)
+			}
+		}()
+	}
+}
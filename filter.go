@@ -0,0 +1,174 @@
+package pingorm
+
+import (
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"gorm.io/gorm/schema"
+)
+
+type FilterOperator string
+
+const (
+	FilterEq      FilterOperator = "eq"
+	FilterNe      FilterOperator = "ne"
+	FilterLt      FilterOperator = "lt"
+	FilterLte     FilterOperator = "lte"
+	FilterGt      FilterOperator = "gt"
+	FilterGte     FilterOperator = "gte"
+	FilterBetween FilterOperator = "between"
+	FilterLike    FilterOperator = "like"
+	FilterIn      FilterOperator = "in"
+	FilterIsNull  FilterOperator = "is_null"
+	FilterNotNull FilterOperator = "not_null"
+	FilterAnd     FilterOperator = "and"
+	FilterOr      FilterOperator = "or"
+)
+
+// Filter is either a condition on Field or an and/or group of Filters
+// i.e: And(Gt("PublishDate", date), Eq("AuthorID", 1))
+type Filter struct {
+	Field    string
+	Operator FilterOperator
+	Values   []interface{}
+	Filters  []Filter
+}
+
+func Eq(field string, value interface{}) Filter {
+	return Filter{Field: field, Operator: FilterEq, Values: []interface{}{value}}
+}
+
+func Ne(field string, value interface{}) Filter {
+	return Filter{Field: field, Operator: FilterNe, Values: []interface{}{value}}
+}
+
+func Lt(field string, value interface{}) Filter {
+	return Filter{Field: field, Operator: FilterLt, Values: []interface{}{value}}
+}
+
+func Lte(field string, value interface{}) Filter {
+	return Filter{Field: field, Operator: FilterLte, Values: []interface{}{value}}
+}
+
+func Gt(field string, value interface{}) Filter {
+	return Filter{Field: field, Operator: FilterGt, Values: []interface{}{value}}
+}
+
+func Gte(field string, value interface{}) Filter {
+	return Filter{Field: field, Operator: FilterGte, Values: []interface{}{value}}
+}
+
+func Between(field string, from, to interface{}) Filter {
+	return Filter{Field: field, Operator: FilterBetween, Values: []interface{}{from, to}}
+}
+
+func Like(field string, pattern string) Filter {
+	return Filter{Field: field, Operator: FilterLike, Values: []interface{}{pattern}}
+}
+
+func In(field string, values ...interface{}) Filter {
+	return Filter{Field: field, Operator: FilterIn, Values: values}
+}
+
+func IsNull(field string) Filter {
+	return Filter{Field: field, Operator: FilterIsNull}
+}
+
+func NotNull(field string) Filter {
+	return Filter{Field: field, Operator: FilterNotNull}
+}
+
+func And(filters ...Filter) Filter {
+	return Filter{Operator: FilterAnd, Filters: filters}
+}
+
+func Or(filters ...Filter) Filter {
+	return Filter{Operator: FilterOr, Filters: filters}
+}
+
+// buildFilterExprs builds the filters of model into expressions to be ANDed
+// in the WHERE clause, every field must be a column of the model's schema.
+func buildFilterExprs(db *gorm.DB, modelSchema *schema.Schema, filters []Filter) ([]clause.Expression, error) {
+	if len(filters) == 0 {
+		return nil, nil
+	}
+
+	exprs := make([]clause.Expression, 0, len(filters))
+	for _, filter := range filters {
+		expr, err := buildFilterExpr(db, modelSchema, filter)
+		if err != nil {
+			return nil, err
+		}
+		exprs = append(exprs, expr)
+	}
+	return exprs, nil
+}
+
+func buildFilterExpr(db *gorm.DB, modelSchema *schema.Schema, filter Filter) (clause.Expression, error) {
+	if filter.Operator == FilterAnd || filter.Operator == FilterOr {
+		if len(filter.Filters) == 0 {
+			return nil, invalidArgument("filter %s requires at least one filter", filter.Operator)
+		}
+
+		exprs := make([]clause.Expression, 0, len(filter.Filters))
+		for _, subFilter := range filter.Filters {
+			expr, err := buildFilterExpr(db, modelSchema, subFilter)
+			if err != nil {
+				return nil, err
+			}
+			exprs = append(exprs, expr)
+		}
+
+		if filter.Operator == FilterOr {
+			return clause.Or(exprs...), nil
+		}
+		return clause.And(exprs...), nil
+	}
+
+	colName := db.NamingStrategy.ColumnName("", filter.Field)
+	if _, ok := modelSchema.FieldsByDBName[colName]; !ok {
+		return nil, invalidArgument("field %s is not a column of %s", filter.Field, modelSchema.Name)
+	}
+	col := clause.Column{Name: colName}
+
+	var expectedValues int
+	switch filter.Operator {
+	case FilterEq, FilterNe, FilterLt, FilterLte, FilterGt, FilterGte, FilterLike:
+		expectedValues = 1
+	case FilterBetween:
+		expectedValues = 2
+	case FilterIsNull, FilterNotNull:
+		expectedValues = 0
+	case FilterIn:
+		expectedValues = len(filter.Values)
+	default:
+		return nil, invalidArgument("unsupported filter operator %s", filter.Operator)
+	}
+	if len(filter.Values) != expectedValues {
+		return nil, invalidArgument("filter %s of %s requires %v value(s)", filter.Operator, filter.Field, expectedValues)
+	}
+
+	switch filter.Operator {
+	case FilterEq:
+		return clause.Eq{Column: col, Value: filter.Values[0]}, nil
+	case FilterNe:
+		return clause.Neq{Column: col, Value: filter.Values[0]}, nil
+	case FilterLt:
+		return clause.Lt{Column: col, Value: filter.Values[0]}, nil
+	case FilterLte:
+		return clause.Lte{Column: col, Value: filter.Values[0]}, nil
+	case FilterGt:
+		return clause.Gt{Column: col, Value: filter.Values[0]}, nil
+	case FilterGte:
+		return clause.Gte{Column: col, Value: filter.Values[0]}, nil
+	case FilterLike:
+		return clause.Like{Column: col, Value: filter.Values[0]}, nil
+	case FilterBetween:
+		return clause.Expr{SQL: "? BETWEEN ? AND ?", Vars: []interface{}{col, filter.Values[0], filter.Values[1]}}, nil
+	case FilterIn:
+		return clause.IN{Column: col, Values: filter.Values}, nil
+	case FilterIsNull:
+		return clause.Expr{SQL: "? IS NULL", Vars: []interface{}{col}}, nil
+	default:
+		return clause.Expr{SQL: "? IS NOT NULL", Vars: []interface{}{col}}, nil
+	}
+}
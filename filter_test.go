@@ -0,0 +1,89 @@
+package pingorm
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm/clause"
+)
+
+func TestBuildFilterExprs(t *testing.T) {
+	publishDate := time.Date(2021, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		model    interface{}
+		filters  []Filter
+		expExprs []clause.Expression
+		expErr   error
+	}{
+		{
+			model:    &Book{},
+			filters:  nil,
+			expExprs: nil,
+		},
+		{
+			model:   &Book{},
+			filters: []Filter{Gt("PublishDate", publishDate), Eq("AuthorID", 1)},
+			expExprs: []clause.Expression{
+				clause.Gt{Column: clause.Column{Name: "publish_date"}, Value: publishDate},
+				clause.Eq{Column: clause.Column{Name: "author_id"}, Value: 1},
+			},
+		},
+		{
+			model: &Author{},
+			filters: []Filter{
+				Or(Like("Name", "Heng%"), And(In("ID", 1, 2), IsNull("Dob"))),
+			},
+			expExprs: []clause.Expression{
+				clause.Or(
+					clause.Like{Column: clause.Column{Name: "name"}, Value: "Heng%"},
+					clause.And(
+						clause.IN{Column: clause.Column{Name: "id"}, Values: []interface{}{1, 2}},
+						clause.Expr{SQL: "? IS NULL", Vars: []interface{}{clause.Column{Name: "dob"}}},
+					),
+				),
+			},
+		},
+		{
+			model:   &Author{},
+			filters: []Filter{Between("Dob", publishDate, publishDate), NotNull("Sex"), Ne("Sex", "Male")},
+			expExprs: []clause.Expression{
+				clause.Expr{SQL: "? BETWEEN ? AND ?", Vars: []interface{}{clause.Column{Name: "dob"}, publishDate, publishDate}},
+				clause.Expr{SQL: "? IS NOT NULL", Vars: []interface{}{clause.Column{Name: "sex"}}},
+				clause.Neq{Column: clause.Column{Name: "sex"}, Value: "Male"},
+			},
+		},
+		{
+			model:   &Author{},
+			filters: []Filter{Eq("Title", "Pingorm")},
+			expErr:  &InvalidArgumentError{Reason: "field Title is not a column of Author"},
+		},
+		{
+			model:   &Author{},
+			filters: []Filter{{Field: "Name", Operator: FilterBetween, Values: []interface{}{"A"}}},
+			expErr:  &InvalidArgumentError{Reason: "filter between of Name requires 2 value(s)"},
+		},
+		{
+			model:   &Author{},
+			filters: []Filter{Or()},
+			expErr:  &InvalidArgumentError{Reason: "filter or requires at least one filter"},
+		},
+	}
+
+	for _, tc := range tests {
+		func() {
+			req := require.New(t)
+
+			db, err := OpenDb(dbConString)
+			req.Nil(err)
+
+			modelSchema, err := parseSchema(db, tc.model)
+			req.Nil(err)
+
+			exprs, errBuild := buildFilterExprs(db, modelSchema, tc.filters)
+			req.Equal(tc.expErr, errBuild)
+			req.Equal(tc.expExprs, exprs)
+		}()
+	}
+}
@@ -1,15 +1,40 @@
 module github.com/appeanix/pingorm
 
-go 1.16
+go 1.18
 
-require ( // indirect
-	github.com/alexflint/go-filemutex v1.1.0 // indirect
+require (
+	github.com/denisenkom/go-mssqldb v0.12.0
 	github.com/go-sql-driver/mysql v1.6.0
 	github.com/icza/gox v0.0.0-20210726201659-cd40a3f8d324
+	github.com/jackc/pgconn v1.12.1
 	github.com/khaiql/dbcleaner v2.3.0+incompatible
-	github.com/lib/pq v1.10.3 // indirect
-	github.com/mattn/go-sqlite3 v1.14.8 // indirect
+	github.com/mattn/go-sqlite3 v1.14.12
 	github.com/stretchr/testify v1.7.0
 	gorm.io/driver/mysql v1.3.3
+	gorm.io/driver/postgres v1.3.7
+	gorm.io/driver/sqlite v1.3.4
+	gorm.io/driver/sqlserver v1.3.1
 	gorm.io/gorm v1.23.5
 )
+
+require (
+	github.com/alexflint/go-filemutex v1.1.0 // indirect
+	github.com/davecgh/go-spew v1.1.1 // indirect
+	github.com/golang-sql/civil v0.0.0-20190719163853-cb61b32ac6fe // indirect
+	github.com/golang-sql/sqlexp v0.0.0-20170517235910-f1bb20e5a188 // indirect
+	github.com/jackc/chunkreader/v2 v2.0.1 // indirect
+	github.com/jackc/pgio v1.0.0 // indirect
+	github.com/jackc/pgpassfile v1.0.0 // indirect
+	github.com/jackc/pgproto3/v2 v2.3.0 // indirect
+	github.com/jackc/pgservicefile v0.0.0-20200714003250-2b9c44734f2b // indirect
+	github.com/jackc/pgtype v1.11.0 // indirect
+	github.com/jackc/pgx/v4 v4.16.1 // indirect
+	github.com/jinzhu/inflection v1.0.0 // indirect
+	github.com/jinzhu/now v1.1.5 // indirect
+	github.com/lib/pq v1.10.3 // indirect
+	github.com/pmezard/go-difflib v1.0.0 // indirect
+	github.com/stretchr/objx v0.2.0 // indirect
+	golang.org/x/crypto v0.0.0-20210921155107-089bfa567519 // indirect
+	golang.org/x/text v0.3.7 // indirect
+	gopkg.in/yaml.v3 v3.0.0-20210107192922-496545a6307b // indirect
+)
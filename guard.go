@@ -0,0 +1,111 @@
+package pingorm
+
+import (
+	"errors"
+	"fmt"
+
+	"gorm.io/gorm"
+)
+
+var (
+	ErrRowsAffected   = errors.New("unexpected rows affected")
+	ErrUnsafeMutation = errors.New("unsafe mutation")
+)
+
+// MutationResult is what a Delete or Updates changed.
+type MutationResult struct {
+	RowsAffected int64
+}
+
+// MutationGuard fails a Delete or Updates changing other than the rows
+// expected, the changes are rolled back.
+type MutationGuard struct {
+	// ExactRows fails a mutation changing other than as many rows, nil
+	// leaves the rows changed unchecked.
+	ExactRows *int64
+	// MaxRows fails a mutation changing more rows, nil leaves it unchecked.
+	MaxRows *int64
+	// Safe refuses a mutation with an empty key list or matching every row
+	// of the table, even by an explicit key list of every row left.
+	Safe bool
+}
+
+// RowsAffectedError reports a mutation failing its MutationGuard, it is
+// ErrRowsAffected to errors.Is.
+type RowsAffectedError struct {
+	ExactRows *int64
+	MaxRows   *int64
+	Actual    int64
+}
+
+func (err *RowsAffectedError) Error() string {
+	if err.ExactRows != nil {
+		return fmt.Sprintf("expected %d rows affected, got %d", *err.ExactRows, err.Actual)
+	}
+	return fmt.Sprintf("expected at most %d rows affected, got %d", *err.MaxRows, err.Actual)
+}
+
+func (err *RowsAffectedError) Is(target error) bool {
+	return target == ErrRowsAffected
+}
+
+// guardMutation runs mutate in a transaction rolled back when the rows it
+// changed fail the guard of option.
+func guardMutation(db *gorm.DB, option QuerySelector, isEmpty bool, mutate func(tx *gorm.DB) (int64, error)) (MutationResult, error) {
+	guard := option.GetGuard()
+	if isEmpty {
+		if guard != nil && guard.Safe {
+			return MutationResult{}, fmt.Errorf("%w: the key list is empty", ErrUnsafeMutation)
+		}
+		return MutationResult{}, checkRowsAffected(guard, 0)
+	}
+
+	if guard == nil || (guard.ExactRows == nil && guard.MaxRows == nil) {
+		rowsAffected, err := mutate(db)
+		return MutationResult{RowsAffected: rowsAffected}, err
+	}
+
+	var result MutationResult
+	err := db.Transaction(func(tx *gorm.DB) error {
+		rowsAffected, err := mutate(tx)
+		if err != nil {
+			return err
+		}
+		result.RowsAffected = rowsAffected
+		return checkRowsAffected(guard, rowsAffected)
+	})
+	if err != nil {
+		return MutationResult{}, err
+	}
+	return result, nil
+}
+
+func checkRowsAffected(guard *MutationGuard, rowsAffected int64) error {
+	if guard == nil {
+		return nil
+	}
+	if (guard.ExactRows != nil && rowsAffected != *guard.ExactRows) || (guard.MaxRows != nil && rowsAffected > *guard.MaxRows) {
+		return &RowsAffectedError{ExactRows: guard.ExactRows, MaxRows: guard.MaxRows, Actual: rowsAffected}
+	}
+	return nil
+}
+
+// checkSafe refuses the mutation of query in safe mode when it matches every
+// row of model that db can see.
+func checkSafe(db *gorm.DB, query *gorm.DB, model interface{}, option QuerySelector) error {
+	if guard := option.GetGuard(); guard == nil || !guard.Safe {
+		return nil
+	}
+
+	var matched, total int64
+	if err := query.Session(&gorm.Session{}).Set(usePrimaryKey, true).Model(model).Count(&matched).Error; err != nil {
+		return err
+	}
+	if err := db.Session(&gorm.Session{}).Set(usePrimaryKey, true).Model(model).Count(&total).Error; err != nil {
+		return err
+	}
+	if total > 0 && matched >= total {
+		return fmt.Errorf("%w: the keys match every row of the table", ErrUnsafeMutation)
+	}
+	return nil
+}
@@ -0,0 +1,131 @@
+package pingorm
+
+import (
+	"testing"
+
+	"github.com/icza/gox/gox"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+)
+
+func TestMutationGuard(t *testing.T) {
+	repo := TypedRepo[Author]{}
+
+	tests := []struct {
+		write      func(db *gorm.DB) (MutationResult, error)
+		expResult  MutationResult
+		expErr     error
+		expAuthors []Author
+	}{
+		// It should report the rows affected
+		{
+			write: func(db *gorm.DB) (MutationResult, error) {
+				return repo.DeleteResult(db, []uint32{1, 2, 4}, QueryOption{})
+			},
+			expResult:  MutationResult{RowsAffected: 2},
+			expAuthors: []Author{{ID: 3, Name: "Dara"}},
+		},
+		{
+			write: func(db *gorm.DB) (MutationResult, error) {
+				return repo.UpdatesResult(db, []uint32{1, 2}, map[string]interface{}{"name": "Sok"}, QueryOption{Guard: &MutationGuard{ExactRows: gox.NewInt64(2)}})
+			},
+			expResult:  MutationResult{RowsAffected: 2},
+			expAuthors: []Author{{ID: 1, Name: "Sok"}, {ID: 2, Name: "Sok"}, {ID: 3, Name: "Dara"}},
+		},
+		// It should roll back the rows affected other than expected
+		{
+			write: func(db *gorm.DB) (MutationResult, error) {
+				return repo.DeleteResult(db, []uint32{1, 2, 4}, QueryOption{Guard: &MutationGuard{ExactRows: gox.NewInt64(3)}})
+			},
+			expErr:     &RowsAffectedError{ExactRows: gox.NewInt64(3), Actual: 2},
+			expAuthors: []Author{{ID: 1, Name: "Henglong"}, {ID: 2, Name: "Vicheka"}, {ID: 3, Name: "Dara"}},
+		},
+		{
+			write: func(db *gorm.DB) (MutationResult, error) {
+				return repo.UpdatesResult(db, []uint32{1, 2}, map[string]interface{}{"name": "Sok"}, QueryOption{Guard: &MutationGuard{MaxRows: gox.NewInt64(1)}})
+			},
+			expErr:     &RowsAffectedError{MaxRows: gox.NewInt64(1), Actual: 2},
+			expAuthors: []Author{{ID: 1, Name: "Henglong"}, {ID: 2, Name: "Vicheka"}, {ID: 3, Name: "Dara"}},
+		},
+		// It should expect no row to be affected
+		{
+			write: func(db *gorm.DB) (MutationResult, error) {
+				return repo.DeleteResult(db, []uint32{4, 5}, QueryOption{Guard: &MutationGuard{ExactRows: gox.NewInt64(0)}})
+			},
+			expAuthors: []Author{{ID: 1, Name: "Henglong"}, {ID: 2, Name: "Vicheka"}, {ID: 3, Name: "Dara"}},
+		},
+		{
+			write: func(db *gorm.DB) (MutationResult, error) {
+				return repo.DeleteResult(db, []uint32{3, 4}, QueryOption{Guard: &MutationGuard{ExactRows: gox.NewInt64(0)}})
+			},
+			expErr:     &RowsAffectedError{ExactRows: gox.NewInt64(0), Actual: 1},
+			expAuthors: []Author{{ID: 1, Name: "Henglong"}, {ID: 2, Name: "Vicheka"}, {ID: 3, Name: "Dara"}},
+		},
+		// It should refuse an empty key list or every row in safe mode
+		{
+			write: func(db *gorm.DB) (MutationResult, error) {
+				return repo.DeleteResult(db, []uint32{}, QueryOption{Guard: &MutationGuard{Safe: true}})
+			},
+			expErr:     ErrUnsafeMutation,
+			expAuthors: []Author{{ID: 1, Name: "Henglong"}, {ID: 2, Name: "Vicheka"}, {ID: 3, Name: "Dara"}},
+		},
+		{
+			write: func(db *gorm.DB) (MutationResult, error) {
+				return repo.DeleteResult(db, []uint32{1, 2, 3}, QueryOption{HardDelete: true, Guard: &MutationGuard{Safe: true}})
+			},
+			expErr:     ErrUnsafeMutation,
+			expAuthors: []Author{{ID: 1, Name: "Henglong"}, {ID: 2, Name: "Vicheka"}, {ID: 3, Name: "Dara"}},
+		},
+		{
+			write: func(db *gorm.DB) (MutationResult, error) {
+				return repo.UpdatesResult(db, []uint32{1, 2}, map[string]interface{}{"name": "Sok"}, QueryOption{Guard: &MutationGuard{Safe: true}})
+			},
+			expResult:  MutationResult{RowsAffected: 2},
+			expAuthors: []Author{{ID: 1, Name: "Sok"}, {ID: 2, Name: "Sok"}, {ID: 3, Name: "Dara"}},
+		},
+		// It should tell the keys from the table on a chained db in safe mode
+		{
+			write: func(db *gorm.DB) (MutationResult, error) {
+				return repo.DeleteResult(UsePrimary(db), []uint32{1}, QueryOption{Guard: &MutationGuard{Safe: true}})
+			},
+			expResult:  MutationResult{RowsAffected: 1},
+			expAuthors: []Author{{ID: 2, Name: "Vicheka"}, {ID: 3, Name: "Dara"}},
+		},
+		{
+			write: func(db *gorm.DB) (MutationResult, error) {
+				return repo.UpdatesResult(UsePrimary(db), []uint32{1}, map[string]interface{}{"name": "Sok"}, QueryOption{Guard: &MutationGuard{Safe: true}})
+			},
+			expResult:  MutationResult{RowsAffected: 1},
+			expAuthors: []Author{{ID: 1, Name: "Sok"}, {ID: 2, Name: "Vicheka"}, {ID: 3, Name: "Dara"}},
+		},
+	}
+
+	for _, tc := range tests {
+		func() {
+			req := require.New(t)
+
+			cleanTables()
+
+			db, err := OpenDb(dbConString)
+			req.Nil(err)
+			for _, author := range []Author{{ID: 1, Name: "Henglong"}, {ID: 2, Name: "Vicheka"}, {ID: 3, Name: "Dara"}} {
+				req.Nil(db.Create(&author).Error)
+			}
+
+			result, err := tc.write(db)
+			if _, ok := tc.expErr.(*RowsAffectedError); ok {
+				req.ErrorIs(err, ErrRowsAffected)
+				req.Equal(tc.expErr, err)
+			} else if tc.expErr != nil {
+				req.ErrorIs(err, tc.expErr)
+			} else {
+				req.Nil(err)
+				req.Equal(tc.expResult, result)
+			}
+
+			authors, err := repo.Get(db, nil, QueryOption{SelectedFields: []string{"ID", "Name"}, Sorts: []Sort{{Field: "ID"}}})
+			req.Nil(err)
+			req.Equal(tc.expAuthors, authors)
+		}()
+	}
+}
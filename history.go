@@ -0,0 +1,354 @@
+package pingorm
+
+import (
+	"context"
+	"encoding/json"
+	"reflect"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"gorm.io/gorm/schema"
+)
+
+type AuditAction string
+
+const (
+	AuditCreate AuditAction = "create"
+	AuditUpdate AuditAction = "update"
+	AuditUpsert AuditAction = "upsert"
+	AuditDelete AuditAction = "delete"
+)
+
+// AuditOptionTrailKey sets the AuditOption or []AuditOption excluding tables
+// or columns from the audit trail of a statement, i.e:
+// db.Set(AuditOptionTrailKey, AuditOption{Table: "author", Skip: true})
+const AuditOptionTrailKey = "app_audit_trail"
+
+const (
+	auditTrailBeforeKey = "app:audit_trail_before"
+	auditTrailCallback  = "app:audit_trail"
+)
+
+type (
+	// AuditLog is an entry of the audit trail, EntityKey is the JSON array of
+	// the primary key values of the row and Changes the JSON object of the
+	// AuditChange of each changed column.
+	AuditLog struct {
+		ID        uint64      `gorm:"primaryKey"`
+		Entity    string      `gorm:"size:64;index:idx_audit_log_entity"`
+		EntityKey string      `gorm:"size:255;index:idx_audit_log_entity"`
+		Action    AuditAction `gorm:"size:16"`
+		UserID    string
+		OrgID     string
+		Changes   string
+		CreatedAt time.Time
+	}
+
+	// AuditChange holds the JSON values of a column, Old is null on create
+	// and New is null on delete.
+	AuditChange struct {
+		Old json.RawMessage `json:"old"`
+		New json.RawMessage `json:"new"`
+	}
+)
+
+func (log AuditLog) Diff() (map[string]AuditChange, error) {
+	changes := map[string]AuditChange{}
+	err := json.Unmarshal([]byte(log.Changes), &changes)
+	return changes, err
+}
+
+// RegisterAuditTrail writes an AuditLog per row created, updated, upserted or
+// deleted on db, along in the transaction of the statement. The actor is
+// resolved as by RegisterAuditIdentityProvider. An option with Skip and no
+// AuditedColumn excludes its whole Table, the audit_log table must be
+// migrated beforehand.
+func RegisterAuditTrail(db *gorm.DB, provider AuditIdentityProvider, options ...AuditOption) {
+	trail := auditTrail{provider: provider, options: options}
+
+	if createCallback := db.Callback().Create(); createCallback.Get(auditTrailCallback) == nil {
+		createCallback.Before("gorm:create").Register(auditTrailBeforeKey, trail.loadBefore(AuditCreate))
+		createCallback.Before("gorm:after_create").Register(auditTrailCallback, trail.record(AuditCreate))
+	} else {
+		createCallback.Replace(auditTrailBeforeKey, trail.loadBefore(AuditCreate))
+		createCallback.Replace(auditTrailCallback, trail.record(AuditCreate))
+	}
+
+	if updateCallback := db.Callback().Update(); updateCallback.Get(auditTrailCallback) == nil {
+		updateCallback.Before("gorm:update").Register(auditTrailBeforeKey, trail.loadBefore(AuditUpdate))
+		updateCallback.Before("gorm:after_update").Register(auditTrailCallback, trail.record(AuditUpdate))
+	} else {
+		updateCallback.Replace(auditTrailBeforeKey, trail.loadBefore(AuditUpdate))
+		updateCallback.Replace(auditTrailCallback, trail.record(AuditUpdate))
+	}
+
+	if deleteCallback := db.Callback().Delete(); deleteCallback.Get(auditTrailCallback) == nil {
+		deleteCallback.Before("gorm:delete").Register(auditTrailBeforeKey, trail.loadBefore(AuditDelete))
+		deleteCallback.Before("gorm:after_delete").Register(auditTrailCallback, trail.record(AuditDelete))
+	} else {
+		deleteCallback.Replace(auditTrailBeforeKey, trail.loadBefore(AuditDelete))
+		deleteCallback.Replace(auditTrailCallback, trail.record(AuditDelete))
+	}
+}
+
+// History reads the audit trail of the row of id, oldest first. The id of a
+// composite primary key is the slice of its values.
+func (repo Repo) History(_db interface{}, id interface{}) ([]AuditLog, error) {
+	return history(_db.(*gorm.DB), repo.Model, id)
+}
+
+// History reads the audit trail of the row of id, oldest first. The id of a
+// composite primary key is the slice of its values.
+func (repo TypedRepo[T]) History(db *gorm.DB, id interface{}) ([]AuditLog, error) {
+	return history(db, new(T), id)
+}
+
+func history(db *gorm.DB, model interface{}, id interface{}) ([]AuditLog, error) {
+	modelSchema, err := parseSchema(db, model)
+	if err != nil {
+		return nil, err
+	}
+
+	keyValues, ok := id.([]interface{})
+	if !ok {
+		keyValues = []interface{}{id}
+	}
+	entityKey, err := json.Marshal(keyValues)
+	if err != nil {
+		return nil, err
+	}
+
+	logs := []AuditLog{}
+	err = db.Where(&AuditLog{Entity: modelSchema.Table, EntityKey: string(entityKey)}).Order("id").Find(&logs).Error
+	return logs, err
+}
+
+type auditTrail struct {
+	provider AuditIdentityProvider
+	options  []AuditOption
+}
+
+func (trail auditTrail) isAudited(tx *gorm.DB) bool {
+	if tx.Error != nil || tx.Statement.Schema == nil {
+		return false
+	}
+	if tx.Statement.Schema.ModelType == reflect.TypeOf(AuditLog{}) {
+		return false
+	}
+	return canSetAuditValue(tx.Statement.Schema.Table, "", trail.statementOptions(tx))
+}
+
+func (trail auditTrail) statementOptions(tx *gorm.DB) []AuditOption {
+	return append(statementAuditOptions(tx, AuditOptionTrailKey), trail.options...)
+}
+
+// statementAction tells an upsert from a create, the others are as their
+// callbacks.
+func statementAction(tx *gorm.DB, action AuditAction) AuditAction {
+	if action == AuditCreate {
+		if _, isUpsert := tx.Statement.Clauses["ON CONFLICT"]; isUpsert {
+			return AuditUpsert
+		}
+	}
+	return action
+}
+
+// loadBefore keeps the rows as they are before the update, delete or upsert
+// of tx, a plain create has none.
+func (trail auditTrail) loadBefore(action AuditAction) func(tx *gorm.DB) {
+	return func(tx *gorm.DB) {
+		if !trail.isAudited(tx) {
+			return
+		}
+
+		var conds []clause.Expression
+		switch statementAction(tx, action) {
+		case AuditCreate:
+			return
+		case AuditUpsert:
+			conds = rowsKeyConds(tx, tx.Statement.ReflectValue)
+		default:
+			if where, ok := tx.Statement.Clauses["WHERE"].Expression.(clause.Where); ok {
+				conds = append(conds, where.Exprs...)
+			}
+			conds = append(conds, primaryKeyConds(tx, tx.Statement.ReflectValue)...)
+			if len(conds) == 0 && !tx.AllowGlobalUpdate {
+				return
+			}
+		}
+
+		rows, err := loadRows(tx, conds, tx.Statement.Unscoped)
+		if err != nil {
+			tx.AddError(err)
+			return
+		}
+		tx.InstanceSet(auditTrailBeforeKey, rows)
+	}
+}
+
+// record logs the changes of each row written by tx, the rows are read back
+// after an update or upsert as the statement may not hold all their values.
+func (trail auditTrail) record(action AuditAction) func(tx *gorm.DB) {
+	return func(tx *gorm.DB) {
+		if !trail.isAudited(tx) || tx.Statement.RowsAffected == 0 {
+			return
+		}
+
+		action := statementAction(tx, action)
+		modelSchema := tx.Statement.Schema
+		identity, _ := resolveAuditIdentity(tx, trail.provider)
+		options := trail.statementOptions(tx)
+
+		var beforeRows, afterRows reflect.Value
+		if val, ok := tx.InstanceGet(auditTrailBeforeKey); ok {
+			beforeRows = val.(reflect.Value)
+		}
+
+		switch action {
+		case AuditCreate:
+			afterRows = tx.Statement.ReflectValue
+		case AuditUpsert, AuditUpdate:
+			keyRows := tx.Statement.ReflectValue
+			if action == AuditUpdate {
+				keyRows = beforeRows
+			}
+			rows, err := loadRows(tx, rowsKeyConds(tx, keyRows), true)
+			if err != nil {
+				tx.AddError(err)
+				return
+			}
+			afterRows = rows
+		}
+
+		before := map[string]reflect.Value{}
+		eachRow(beforeRows, func(row reflect.Value) {
+			before[auditEntityKey(tx, row)] = row
+		})
+
+		var logs []AuditLog
+		addLog := func(entityKey string, oldRow, newRow reflect.Value) {
+			changes := map[string]AuditChange{}
+			for _, field := range modelSchema.Fields {
+				if field.DBName == "" || !canSetAuditValue(modelSchema.Table, field.DBName, options) {
+					continue
+				}
+
+				change := AuditChange{Old: auditValue(tx.Statement.Context, field, oldRow), New: auditValue(tx.Statement.Context, field, newRow)}
+				if string(change.Old) != string(change.New) {
+					changes[field.DBName] = change
+				}
+			}
+			if len(changes) == 0 {
+				return
+			}
+
+			data, err := json.Marshal(changes)
+			if err != nil {
+				tx.AddError(err)
+				return
+			}
+			logs = append(logs, AuditLog{
+				Entity:    modelSchema.Table,
+				EntityKey: entityKey,
+				Action:    action,
+				UserID:    identity.UserID,
+				OrgID:     identity.OrgID,
+				Changes:   string(data),
+				CreatedAt: tx.NowFunc(),
+			})
+		}
+
+		if action == AuditDelete {
+			eachRow(beforeRows, func(row reflect.Value) {
+				addLog(auditEntityKey(tx, row), row, reflect.Value{})
+			})
+		} else {
+			eachRow(afterRows, func(row reflect.Value) {
+				entityKey := auditEntityKey(tx, row)
+				addLog(entityKey, before[entityKey], row)
+			})
+		}
+
+		if len(logs) == 0 || tx.Error != nil {
+			return
+		}
+		if err := tx.Session(&gorm.Session{NewDB: true}).Set(tenantUnscopedKey, true).Create(&logs).Error; err != nil {
+			tx.AddError(err)
+		}
+	}
+}
+
+// loadRows finds the rows of the model of tx matching conds into a slice.
+func loadRows(tx *gorm.DB, conds []clause.Expression, unscoped bool) (reflect.Value, error) {
+	rows := reflect.New(reflect.SliceOf(tx.Statement.Schema.ModelType))
+	if len(conds) == 0 && !tx.AllowGlobalUpdate {
+		return rows.Elem(), nil
+	}
+
+	query := tx.Session(&gorm.Session{NewDB: true}).
+		Set(tenantUnscopedKey, true).
+		Set(usePrimaryKey, true).
+		Model(reflect.New(tx.Statement.Schema.ModelType).Interface())
+	if len(conds) > 0 {
+		query = query.Where(clause.And(conds...))
+	}
+	if unscoped {
+		query = query.Unscoped()
+	}
+	err := query.Find(rows.Interface()).Error
+	return rows.Elem(), err
+}
+
+// rowsKeyConds matches the rows by their primary keys, or by the conflict
+// target of an upsert, the rows without any are left out.
+func rowsKeyConds(tx *gorm.DB, rows reflect.Value) []clause.Expression {
+	fields := statementConflictFields(tx)
+	var rowConds []clause.Expression
+	eachRow(rows, func(row reflect.Value) {
+		if conds := conflictKeyConds(tx.Statement.Context, fields, row); len(conds) == len(fields) && len(conds) > 0 {
+			rowConds = append(rowConds, clause.And(conds...))
+		}
+	})
+	if len(rowConds) == 0 {
+		return []clause.Expression{clause.Expr{SQL: "1 = 0"}}
+	}
+	return []clause.Expression{clause.Or(rowConds...)}
+}
+
+func eachRow(rows reflect.Value, fn func(row reflect.Value)) {
+	if !rows.IsValid() {
+		return
+	}
+	rows = reflect.Indirect(rows)
+	switch rows.Kind() {
+	case reflect.Slice, reflect.Array:
+		for i := 0; i < rows.Len(); i++ {
+			fn(reflect.Indirect(rows.Index(i)))
+		}
+	case reflect.Struct:
+		fn(rows)
+	}
+}
+
+func auditEntityKey(tx *gorm.DB, row reflect.Value) string {
+	values := make([]interface{}, len(tx.Statement.Schema.PrimaryFields))
+	for i, field := range tx.Statement.Schema.PrimaryFields {
+		values[i], _ = field.ValueOf(tx.Statement.Context, row)
+	}
+	data, _ := json.Marshal(values)
+	return string(data)
+}
+
+// auditValue is the JSON value of field in row, null when there is no row.
+func auditValue(ctx context.Context, field *schema.Field, row reflect.Value) json.RawMessage {
+	if !row.IsValid() {
+		return json.RawMessage("null")
+	}
+	value, _ := field.ValueOf(ctx, row)
+	data, err := json.Marshal(value)
+	if err != nil {
+		return json.RawMessage("null")
+	}
+	return data
+}
@@ -0,0 +1,132 @@
+package pingorm
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+)
+
+func TestAuditTrail(t *testing.T) {
+	repo := TypedRepo[Author]{}
+	create := func(db *gorm.DB) error {
+		_, err := repo.Create(db, Author{ID: 1, Name: "Henglong"}, QueryOption{})
+		return err
+	}
+
+	type expLog struct {
+		action  AuditAction
+		changes map[string][2]string
+	}
+
+	tests := []struct {
+		write   func(db *gorm.DB) error
+		expLogs []expLog
+	}{
+		// It should log the values created
+		{
+			write: create,
+			expLogs: []expLog{
+				{action: AuditCreate, changes: map[string][2]string{"id": {"null", "1"}, "name": {"null", `"Henglong"`}}},
+			},
+		},
+		// It should log the changed values only
+		{
+			write: func(db *gorm.DB) error {
+				if err := create(db); err != nil {
+					return err
+				}
+				return repo.Updates(db, []uint32{1}, map[string]interface{}{"name": "Vicheka", "sex": "F"}, QueryOption{})
+			},
+			expLogs: []expLog{
+				{action: AuditCreate, changes: map[string][2]string{"id": {"null", "1"}, "name": {"null", `"Henglong"`}}},
+				{action: AuditUpdate, changes: map[string][2]string{"name": {`"Henglong"`, `"Vicheka"`}}},
+			},
+		},
+		{
+			write: func(db *gorm.DB) error {
+				if err := create(db); err != nil {
+					return err
+				}
+				_, err := repo.Upsert(db, []Author{{ID: 1, Name: "Vicheka"}}, QueryOption{SelectedFields: []string{"name"}})
+				return err
+			},
+			expLogs: []expLog{
+				{action: AuditCreate, changes: map[string][2]string{"id": {"null", "1"}, "name": {"null", `"Henglong"`}}},
+				{action: AuditUpsert, changes: map[string][2]string{"name": {`"Henglong"`, `"Vicheka"`}}},
+			},
+		},
+		// It should log the values deleted
+		{
+			write: func(db *gorm.DB) error {
+				if err := create(db); err != nil {
+					return err
+				}
+				return repo.Delete(db, []uint32{1}, QueryOption{})
+			},
+			expLogs: []expLog{
+				{action: AuditCreate, changes: map[string][2]string{"id": {"null", "1"}, "name": {"null", `"Henglong"`}}},
+				{action: AuditDelete, changes: map[string][2]string{"id": {"1", "null"}, "name": {`"Henglong"`, "null"}}},
+			},
+		},
+		// It should not log an excluded table
+		{
+			write: func(db *gorm.DB) error {
+				return create(db.Set(AuditOptionTrailKey, AuditOption{Table: "author", Skip: true}))
+			},
+			expLogs: []expLog{},
+		},
+		// It should not log a write rolled back
+		{
+			write: func(db *gorm.DB) error {
+				_ = WithTx(context.Background(), db, func(tx *gorm.DB) error {
+					if err := create(tx); err != nil {
+						return err
+					}
+					return errors.New("rollback")
+				})
+				return nil
+			},
+			expLogs: []expLog{},
+		},
+	}
+
+	for _, tc := range tests {
+		func() {
+			req := require.New(t)
+
+			cleanTables()
+			db := openFixture(req, []interface{}{&AuditLog{}})
+			defer closeDb(db)
+
+			RegisterAuditTrail(db, AuditIdentity{UserID: "user01", OrgID: "org01"},
+				AuditOption{Table: "author", AuditedColumn: "contact_number", Skip: true},
+				AuditOption{Table: "author", AuditedColumn: "sex", Skip: true},
+				AuditOption{Table: "author", AuditedColumn: "dob", Skip: true},
+			)
+
+			req.Nil(tc.write(db))
+
+			logs, err := repo.History(db, uint32(1))
+			req.Nil(err)
+			req.Len(logs, len(tc.expLogs))
+			for i, log := range logs {
+				req.Equal(tc.expLogs[i].action, log.Action)
+				req.Equal("author", log.Entity)
+				req.Equal("[1]", log.EntityKey)
+				req.Equal("user01", log.UserID)
+				req.Equal("org01", log.OrgID)
+
+				diff, err := log.Diff()
+				req.Nil(err)
+				changes := map[string][2]string{}
+				for column, change := range diff {
+					changes[column] = [2]string{string(change.Old), string(change.New)}
+				}
+				req.Equal(tc.expLogs[i].changes, changes)
+			}
+		}()
+	}
+}
@@ -0,0 +1,343 @@
+package pingorm
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	"sync"
+	"sync/atomic"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"gorm.io/gorm/schema"
+)
+
+const (
+	keysTableKey = "app:keys_table"
+	// placeholderMargin leaves room in a statement for the placeholders of
+	// the filters next to the keys.
+	placeholderMargin = 100
+)
+
+var keysTableSeq uint64
+
+// KeyListOption is how Get and Delete take key lists too large for a single
+// "IN ?" list, which are split into chunks of keys by default.
+type KeyListOption struct {
+	// ChunkSize is the number of keys per statement, as many as the dialect
+	// takes placeholders when 0.
+	ChunkSize int
+	// Concurrency is the number of chunks a Get queries at once, out of a
+	// transaction only.
+	Concurrency int
+	// TempTableThreshold loads the key lists of as many keys or more into a
+	// temporary table the rows are matched against, 0 never does but for
+	// the Get chunks can not serve i.e: sorted or paginated.
+	TempTableThreshold int
+}
+
+type keyListPlan int
+
+const (
+	keyListPlain keyListPlan = iota
+	keyListChunks
+	keyListTempTable
+)
+
+// planKeyList tells how to match sliceOfIDs, along with the size of its
+// chunks.
+func planKeyList(db *gorm.DB, modelSchema *schema.Schema, sliceOfIDs interface{}, option QuerySelector, canChunk bool) (keyListPlan, int) {
+	keyList := option.GetKeyList()
+	if keyList == nil {
+		keyList = &KeyListOption{}
+	}
+	if sliceOfIDs == nil || reflect.TypeOf(sliceOfIDs).Kind() != reflect.Slice {
+		return keyListPlain, 0
+	}
+	count := reflect.ValueOf(sliceOfIDs).Len()
+
+	if keyList.TempTableThreshold > 0 && count >= keyList.TempTableThreshold {
+		return keyListTempTable, 0
+	}
+
+	size := keyList.ChunkSize
+	if size <= 0 {
+		size = (maxPlaceholders(db) - placeholderMargin) / len(keyColumns(db, modelSchema, option))
+	}
+	switch {
+	case count <= size:
+		return keyListPlain, 0
+	case canChunk:
+		return keyListChunks, size
+	}
+	return keyListTempTable, 0
+}
+
+// findByKeyList finds into ptrToSlice the rows of findQuery, by chunks of
+// keys or through a temporary table when the keys are too many for a single
+// statement. findErr is the error of the query itself.
+func findByKeyList(db *gorm.DB, model interface{}, sliceOfIDs interface{}, option QuerySelector, ptrToSlice interface{}) (keysetColumns []sortColumn, findErr error, err error) {
+	modelSchema, err := parseSchema(db, model)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	// The chunks can not be sorted or paginated as a whole
+	canChunk := len(option.GetSorts()) == 0 && option.GetPagination() == nil
+	plan, size := planKeyList(db, modelSchema, sliceOfIDs, option, canChunk)
+
+	switch plan {
+	case keyListChunks:
+		return nil, nil, findByChunks(db, model, sliceOfIDs, option, ptrToSlice, size)
+	case keyListTempTable:
+		err = withKeysTable(db, modelSchema, sliceOfIDs, option, func(tx *gorm.DB) error {
+			var query *gorm.DB
+			if query, keysetColumns, err = findQuery(tx, model, sliceOfIDs, option); err != nil {
+				return err
+			}
+			findErr = query.Find(ptrToSlice).Error
+			return findErr
+		})
+		if err != nil && err == findErr {
+			err = nil
+		}
+		return keysetColumns, findErr, err
+	}
+
+	query, keysetColumns, err := findQuery(db, model, sliceOfIDs, option)
+	if err != nil {
+		return nil, nil, err
+	}
+	return keysetColumns, query.Find(ptrToSlice).Error, nil
+}
+
+// findByChunks finds the rows of each chunk of sliceOfIDs, option.KeyList
+// Concurrency chunks at once, and appends them to ptrToSlice in the order of
+// the chunks.
+func findByChunks(db *gorm.DB, model interface{}, sliceOfIDs interface{}, option QuerySelector, ptrToSlice interface{}, size int) error {
+	chunks := chunkKeys(sliceOfIDs, size)
+	results := make([]reflect.Value, len(chunks))
+	errs := make([]error, len(chunks))
+
+	find := func(i int) {
+		query, _, err := findQuery(db, model, chunks[i], option)
+		if err != nil {
+			errs[i] = err
+			return
+		}
+		results[i] = reflect.New(reflect.TypeOf(ptrToSlice).Elem())
+		errs[i] = query.Find(results[i].Interface()).Error
+	}
+
+	concurrency := 1
+	if keyList := option.GetKeyList(); keyList != nil && keyList.Concurrency > 1 && !inTransaction(db) {
+		concurrency = keyList.Concurrency
+	}
+	if concurrency == 1 {
+		for i := range chunks {
+			if find(i); errs[i] != nil {
+				return errs[i]
+			}
+		}
+	} else {
+		var wg sync.WaitGroup
+		sem := make(chan struct{}, concurrency)
+		for i := range chunks {
+			wg.Add(1)
+			sem <- struct{}{}
+			go func(i int) {
+				defer func() { <-sem; wg.Done() }()
+				find(i)
+			}(i)
+		}
+		wg.Wait()
+		for _, err := range errs {
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	rows := reflect.ValueOf(ptrToSlice).Elem()
+	for _, result := range results {
+		rows = reflect.AppendSlice(rows, result.Elem())
+	}
+	reflect.ValueOf(ptrToSlice).Elem().Set(rows)
+	return nil
+}
+
+// deleteByKeyList deletes as deleteByKeys does, by chunks of keys in a
+// transaction or through a temporary table when the keys are too many for a
+// single statement.
+func deleteByKeyList(db *gorm.DB, ptrToModel interface{}, sliceOfIDs interface{}, option QuerySelector) (int64, error) {
+	modelSchema, err := parseSchema(db, ptrToModel)
+	if err != nil {
+		return 0, err
+	}
+
+	var rowsAffected int64
+	switch plan, size := planKeyList(db, modelSchema, sliceOfIDs, option, true); plan {
+	case keyListChunks:
+		err = db.Transaction(func(tx *gorm.DB) error {
+			for _, chunk := range chunkKeys(sliceOfIDs, size) {
+				deleted, err := deleteByKeys(tx, ptrToModel, chunk, option)
+				if err != nil {
+					return err
+				}
+				rowsAffected += deleted
+			}
+			return nil
+		})
+	case keyListTempTable:
+		err = withKeysTable(db, modelSchema, sliceOfIDs, option, func(tx *gorm.DB) error {
+			rowsAffected, err = deleteByKeys(tx, ptrToModel, sliceOfIDs, option)
+			return err
+		})
+	default:
+		return deleteByKeys(db, ptrToModel, sliceOfIDs, option)
+	}
+	if err != nil {
+		return 0, err
+	}
+	return rowsAffected, nil
+}
+
+func chunkKeys(sliceOfIDs interface{}, size int) []interface{} {
+	keys := reflect.ValueOf(sliceOfIDs)
+	var chunks []interface{}
+	for start := 0; start < keys.Len(); start += size {
+		end := start + size
+		if end > keys.Len() {
+			end = keys.Len()
+		}
+		chunks = append(chunks, keys.Slice(start, end).Interface())
+	}
+	return chunks
+}
+
+// withKeysTable loads sliceOfIDs into a temporary table for the time of fn,
+// in a transaction holding the connection the table lives in. whereKeys
+// matches the rows against the table in the queries of the tx fn gets.
+func withKeysTable(db *gorm.DB, modelSchema *schema.Schema, sliceOfIDs interface{}, option QuerySelector, fn func(tx *gorm.DB) error) error {
+	keyCols := keyColumns(db, modelSchema, option)
+	_, args, err := buildWhereExprByKeys(db, modelSchema, sliceOfIDs, option)
+	if err != nil {
+		return err
+	}
+
+	var rows [][]interface{}
+	switch values := args.(type) {
+	case [][]interface{}:
+		rows = values
+	case []interface{}:
+		for _, value := range values {
+			rows = append(rows, []interface{}{value})
+		}
+	}
+
+	table, err := newKeysTable(db, modelSchema, keyCols)
+	if err != nil {
+		return err
+	}
+
+	return db.Transaction(func(tx *gorm.DB) (err error) {
+		if err := tx.Exec(table.create).Error; err != nil {
+			return err
+		}
+		// MySQL does not roll back the creation of a temporary table and SQL
+		// Server keeps a #table as long as the connection, which is pooled
+		defer func() {
+			if dropErr := tx.Exec(table.drop).Error; err == nil {
+				err = dropErr
+			}
+		}()
+
+		size := maxPlaceholders(tx) / len(keyCols)
+		rowPlaceholders := "(" + strings.TrimSuffix(strings.Repeat("?, ", len(keyCols)), ", ") + ")"
+		for start := 0; start < len(rows); start += size {
+			end := start + size
+			if end > len(rows) {
+				end = len(rows)
+			}
+
+			values := make([]string, 0, end-start)
+			vars := make([]interface{}, 0, (end-start)*len(keyCols))
+			for _, row := range rows[start:end] {
+				values = append(values, rowPlaceholders)
+				vars = append(vars, row...)
+			}
+			insert := fmt.Sprintf("INSERT INTO %s (%s) VALUES %s", table.quotedName, strings.Join(table.quotedCols, ", "), strings.Join(values, ", "))
+			if err := tx.Exec(insert, vars...).Error; err != nil {
+				return err
+			}
+		}
+
+		return fn(tx.Set(keysTableKey, table.name))
+	})
+}
+
+// keysTable is the temporary table withKeysTable loads the keys into.
+type keysTable struct {
+	name       string
+	quotedName string
+	quotedCols []string
+	create     string
+	drop       string
+}
+
+// newKeysTable names a new temporary table of the key columns keyCols and
+// tells how to create and drop it on the dialect of db.
+func newKeysTable(db *gorm.DB, modelSchema *schema.Schema, keyCols []string) (*keysTable, error) {
+	table := &keysTable{
+		name:       fmt.Sprintf("pingorm_keys_%d", atomic.AddUint64(&keysTableSeq, 1)),
+		quotedCols: make([]string, len(keyCols)),
+	}
+
+	columnDefs := make([]string, len(keyCols))
+	for i, column := range keyCols {
+		field := modelSchema.LookUpField(column)
+		if field == nil {
+			return nil, invalidArgument("key %s is not a column of %s", column, modelSchema.Name)
+		}
+		// The keys are inserted as they are, which an identity column refuses,
+		// and MySQL takes no auto increment column that is not a key
+		plainField := *field
+		plainField.AutoIncrement = false
+		table.quotedCols[i] = db.Statement.Quote(column)
+		columnDefs[i] = table.quotedCols[i] + " " + db.Dialector.DataTypeOf(&plainField)
+	}
+
+	create, drop := "CREATE TEMPORARY TABLE", "DROP TABLE"
+	switch Dialect(db.Dialector.Name()) {
+	case DialectSQLite:
+		create = "CREATE TEMP TABLE"
+	case DialectSQLServer:
+		table.name, create = "#"+table.name, "CREATE TABLE"
+	case DialectMySQL:
+		drop = "DROP TEMPORARY TABLE"
+	}
+	table.quotedName = db.Statement.Quote(table.name)
+	table.create = fmt.Sprintf("%s %s (%s)", create, table.quotedName, strings.Join(columnDefs, ", "))
+	table.drop = fmt.Sprintf("%s %s", drop, table.quotedName)
+	return table, nil
+}
+
+// keysTableExpr matches the rows of the current table whose key columns are
+// in the temporary table name.
+func keysTableExpr(name string, keyCols []string) clause.Expression {
+	conds := make([]string, len(keyCols))
+	vars := []interface{}{clause.Table{Name: name}}
+	for i, column := range keyCols {
+		conds[i] = "? = ?"
+		vars = append(vars,
+			clause.Column{Table: name, Name: column},
+			clause.Column{Table: clause.CurrentTable, Name: column},
+		)
+	}
+	return clause.Expr{SQL: "EXISTS (SELECT 1 FROM ? WHERE " + strings.Join(conds, " AND ") + ")", Vars: vars}
+}
+
+func inTransaction(db *gorm.DB) bool {
+	_, ok := db.Statement.ConnPool.(gorm.TxCommitter)
+	return ok
+}
@@ -0,0 +1,157 @@
+package pingorm
+
+import (
+	"fmt"
+	"sync/atomic"
+	"testing"
+
+	"github.com/icza/gox/gox"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+)
+
+func TestKeyList(t *testing.T) {
+	repo := TypedRepo[enrollment]{}
+	composite := [][]interface{}{{1, 10}, {2, 20}, {3, 10}, {4, 20}, {9, 90}}
+	all := func(db *gorm.DB) ([]enrollment, error) {
+		return repo.Get(db, nil, QueryOption{Sorts: []Sort{{Field: "StudentID"}, {Field: "CourseID"}}})
+	}
+
+	tests := []struct {
+		write          func(db *gorm.DB) ([]enrollment, error)
+		expEnrollments []enrollment
+	}{
+		// It should merge the rows of the chunks in the order of the chunks
+		{
+			write: func(db *gorm.DB) ([]enrollment, error) {
+				return repo.Get(db, []uint32{4, 1, 3}, QueryOption{Keys: []string{"StudentID"}, KeyList: &KeyListOption{ChunkSize: 2}})
+			},
+			expEnrollments: []enrollment{{1, 10, "B"}, {1, 20, "B"}, {4, 10, "B"}, {4, 20, "B"}, {3, 10, "B"}, {3, 20, "B"}},
+		},
+		{
+			write: func(db *gorm.DB) ([]enrollment, error) {
+				return repo.Get(db, composite, QueryOption{KeyList: &KeyListOption{ChunkSize: 1, Concurrency: 3}})
+			},
+			expEnrollments: []enrollment{{1, 10, "B"}, {2, 20, "B"}, {3, 10, "B"}, {4, 20, "B"}},
+		},
+		// It should leave a chained db as it is across the chunks
+		{
+			write: func(db *gorm.DB) ([]enrollment, error) {
+				return repo.Get(UsePrimary(db), []uint32{4, 1, 3}, QueryOption{Keys: []string{"StudentID"}, KeyList: &KeyListOption{ChunkSize: 2}})
+			},
+			expEnrollments: []enrollment{{1, 10, "B"}, {1, 20, "B"}, {4, 10, "B"}, {4, 20, "B"}, {3, 10, "B"}, {3, 20, "B"}},
+		},
+		{
+			write: func(db *gorm.DB) ([]enrollment, error) {
+				return repo.Get(UsePrimary(db), composite, QueryOption{KeyList: &KeyListOption{ChunkSize: 1, Concurrency: 3}})
+			},
+			expEnrollments: []enrollment{{1, 10, "B"}, {2, 20, "B"}, {3, 10, "B"}, {4, 20, "B"}},
+		},
+		// It should match the keys loaded into a temporary table
+		{
+			write: func(db *gorm.DB) ([]enrollment, error) {
+				return repo.Get(db, composite, QueryOption{KeyList: &KeyListOption{TempTableThreshold: 2}, Sorts: []Sort{{Field: "StudentID", Desc: true}}})
+			},
+			expEnrollments: []enrollment{{4, 20, "B"}, {3, 10, "B"}, {2, 20, "B"}, {1, 10, "B"}},
+		},
+		// It should fall back on a temporary table for a sorted Get
+		{
+			write: func(db *gorm.DB) ([]enrollment, error) {
+				return repo.Get(db, []uint32{3, 4, 9}, QueryOption{Keys: []string{"StudentID"}, KeyList: &KeyListOption{ChunkSize: 2}, Sorts: []Sort{{Field: "CourseID"}, {Field: "StudentID"}}})
+			},
+			expEnrollments: []enrollment{{3, 10, "B"}, {4, 10, "B"}, {3, 20, "B"}, {4, 20, "B"}},
+		},
+		// It should delete by chunks and by a temporary table alike, reporting all the rows deleted
+		{
+			write: func(db *gorm.DB) ([]enrollment, error) {
+				if err := repo.Delete(db, composite, QueryOption{KeyList: &KeyListOption{ChunkSize: 2}, Guard: &MutationGuard{ExactRows: gox.NewInt64(4)}}); err != nil {
+					return nil, err
+				}
+				return all(db)
+			},
+			expEnrollments: []enrollment{{1, 20, "B"}, {2, 10, "B"}, {3, 20, "B"}, {4, 10, "B"}},
+		},
+		{
+			write: func(db *gorm.DB) ([]enrollment, error) {
+				if err := repo.Delete(db, []uint32{1, 2, 3, 9}, QueryOption{Keys: []string{"StudentID"}, KeyList: &KeyListOption{TempTableThreshold: 3}, Guard: &MutationGuard{ExactRows: gox.NewInt64(6)}}); err != nil {
+					return nil, err
+				}
+				return all(db)
+			},
+			expEnrollments: []enrollment{{4, 10, "B"}, {4, 20, "B"}},
+		},
+	}
+
+	for _, tc := range tests {
+		func() {
+			req := require.New(t)
+
+			db := openFixture(req, []interface{}{&enrollment{}}, []enrollment{
+				{1, 10, "B"}, {1, 20, "B"}, {2, 10, "B"}, {2, 20, "B"},
+				{3, 10, "B"}, {3, 20, "B"}, {4, 10, "B"}, {4, 20, "B"},
+			})
+			defer closeDb(db)
+
+			enrollments, err := tc.write(db)
+			req.Nil(err)
+			req.Equal(tc.expEnrollments, enrollments)
+		}()
+	}
+}
+
+func TestKeyListTempTableDropped(t *testing.T) {
+	req := require.New(t)
+
+	db := openFixture(req, []interface{}{&enrollment{}}, []enrollment{{1, 10, "B"}, {2, 20, "B"}})
+	defer closeDb(db)
+	// A single connection gets the temporary table back if it is left over
+	sqlDB, err := db.DB()
+	req.Nil(err)
+	sqlDB.SetMaxOpenConns(1)
+
+	// It should drop the temporary table of a failing query
+	_, err = TypedRepo[enrollment]{}.Get(db, [][]interface{}{{1, 10}, {2, 20}}, QueryOption{
+		KeyList: &KeyListOption{TempTableThreshold: 1},
+		Filters: []Filter{Eq("Unknown", 1)},
+	})
+	req.ErrorIs(err, ErrInvalidArgument)
+
+	name := fmt.Sprintf("pingorm_keys_%d", atomic.LoadUint64(&keysTableSeq))
+	if testDialect() == DialectSQLServer {
+		name = "#" + name
+	}
+	req.NotNil(db.Exec("SELECT 1 FROM " + db.Statement.Quote(name)).Error)
+}
+
+func TestKeysTableDDL(t *testing.T) {
+	tests := []struct {
+		open      func(req *require.Assertions) *gorm.DB
+		expCreate string
+	}{
+		// It should leave the auto increment of the key out of the column
+		{
+			open:      openMySQLDialector,
+			expCreate: "CREATE TEMPORARY TABLE `pingorm_keys_%d` (`id` int unsigned)",
+		},
+		// It should leave the identity of the key out of the column
+		{
+			open:      openSQLServerDialector,
+			expCreate: `CREATE TABLE "#pingorm_keys_%d" ("id" bigint)`,
+		},
+	}
+
+	for _, tc := range tests {
+		func() {
+			req := require.New(t)
+
+			db := tc.open(req)
+			defer closeDb(db)
+
+			modelSchema, err := parseSchema(db, &Author{})
+			req.Nil(err)
+			table, err := newKeysTable(db, modelSchema, []string{"id"})
+			req.Nil(err)
+			req.Equal(fmt.Sprintf(tc.expCreate, atomic.LoadUint64(&keysTableSeq)), table.create)
+		}()
+	}
+}
@@ -0,0 +1,194 @@
+package pingorm
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"gorm.io/gorm/schema"
+)
+
+const (
+	versionColumn   = "version"
+	versionCheckKey = "app:version_check"
+)
+
+var ErrStaleObject = errors.New("stale object")
+
+// StaleObjectError is reported by an update of rows changed since they were
+// read, Keys holds the primary key of each, as a slice for composite keys.
+// It is ErrStaleObject to errors.Is.
+type StaleObjectError struct {
+	Table string
+	Keys  []interface{}
+}
+
+func (err *StaleObjectError) Error() string {
+	return fmt.Sprintf("stale object of %s with keys %v", err.Table, err.Keys)
+}
+
+func (err *StaleObjectError) Is(target error) bool {
+	return target == ErrStaleObject
+}
+
+type versionCheck struct {
+	field    *schema.Field
+	expected interface{}
+	matched  reflect.Value
+	isModel  bool
+}
+
+// lockVersion increments the version column of the rows updated by tx, and
+// updates only the rows still at the version read when tx carries it, either
+// in the model updated or in the values to update.
+func lockVersion(tx *gorm.DB) {
+	if tx.Error != nil || tx.Statement.Schema == nil || tx.Statement.SQL.Len() > 0 {
+		return
+	}
+	field, ok := tx.Statement.Schema.FieldsByDBName[versionColumn]
+	if !ok {
+		return
+	}
+
+	values, expected, isModel, ok := versionedValues(tx, field)
+	if !ok {
+		return
+	}
+	values[field.DBName] = clause.Expr{SQL: tx.Statement.Quote(field.DBName) + " + 1"}
+	tx.Statement.Dest = values
+	if expected == nil {
+		return
+	}
+
+	var conds []clause.Expression
+	if where, ok := tx.Statement.Clauses["WHERE"].Expression.(clause.Where); ok {
+		conds = append(conds, where.Exprs...)
+	}
+	conds = append(conds, primaryKeyConds(tx, tx.Statement.ReflectValue)...)
+
+	// Leave the statement without conditions to ErrMissingWhereClause
+	if len(conds) == 0 && !tx.AllowGlobalUpdate {
+		return
+	}
+
+	matched, err := loadRows(tx, conds, tx.Statement.Unscoped)
+	if err != nil {
+		tx.AddError(err)
+		return
+	}
+	tx.InstanceSet(versionCheckKey, versionCheck{field: field, expected: expected, matched: matched, isModel: isModel})
+	tx.Statement.AddClause(clause.Where{Exprs: []clause.Expression{
+		clause.Eq{Column: clause.Column{Table: clause.CurrentTable, Name: field.DBName}, Value: expected},
+	}})
+}
+
+// checkVersion fails tx with a StaleObjectError when it updated fewer rows
+// than it matched, the model updated gets its new version otherwise.
+func checkVersion(tx *gorm.DB) {
+	val, ok := tx.InstanceGet(versionCheckKey)
+	if !ok || tx.Error != nil {
+		return
+	}
+	check := val.(versionCheck)
+
+	if tx.Statement.RowsAffected >= int64(check.matched.Len()) {
+		if check.isModel {
+			if next, ok := nextVersion(check.expected); ok {
+				tx.AddError(check.field.Set(tx.Statement.Context, tx.Statement.ReflectValue, next))
+			}
+		}
+		return
+	}
+
+	var staleKeys, matchedKeys []interface{}
+	eachRow(check.matched, func(row reflect.Value) {
+		key := rowKey(tx, row)
+		matchedKeys = append(matchedKeys, key)
+		if version, _ := check.field.ValueOf(tx.Statement.Context, row); fmt.Sprint(version) != fmt.Sprint(check.expected) {
+			staleKeys = append(staleKeys, key)
+		}
+	})
+
+	// The rows changed between the read and the update can not be told apart
+	if len(staleKeys) == 0 {
+		staleKeys = matchedKeys
+	}
+	tx.AddError(&StaleObjectError{Table: tx.Statement.Table, Keys: staleKeys})
+}
+
+// versionedValues converts the values to update of tx to a map, which can
+// hold the increment of the version field. The expected version is the one
+// of the model updated or the one in the map of values, nil when there is
+// none.
+func versionedValues(tx *gorm.DB, field *schema.Field) (values map[string]interface{}, expected interface{}, isModel bool, ok bool) {
+	values = map[string]interface{}{}
+
+	if dest, isMap := tx.Statement.Dest.(map[string]interface{}); isMap {
+		for name, value := range dest {
+			if name == field.DBName || name == field.Name {
+				expected = value
+				continue
+			}
+			values[name] = value
+		}
+		return values, expected, false, true
+	}
+
+	row := reflect.Indirect(reflect.ValueOf(tx.Statement.Dest))
+	if row.Kind() != reflect.Struct || row.Type() != tx.Statement.Schema.ModelType {
+		return nil, nil, false, false
+	}
+
+	// The fields a struct updates, see gorm's ConvertToAssignments
+	selectColumns, restricted := tx.Statement.SelectAndOmitColumns(false, true)
+	for _, f := range tx.Statement.Schema.Fields {
+		if f.DBName == "" || f.PrimaryKey || f == field || !f.Updatable {
+			continue
+		}
+		selected, isSet := selectColumns[f.DBName]
+		value, isZero := f.ValueOf(tx.Statement.Context, row)
+		if (isSet && selected) || (!isSet && !restricted && !isZero) {
+			values[f.DBName] = value
+		}
+	}
+
+	// The values of a bulk update are no model read at a version
+	if !isUpdatedModel(tx.Statement) {
+		return values, nil, false, true
+	}
+	expected, _ = field.ValueOf(tx.Statement.Context, row)
+	return values, expected, true, true
+}
+
+// isUpdatedModel tells whether the values of stmt are the model updated, as
+// in Update, rather than the values of the rows of Model as in Updates.
+func isUpdatedModel(stmt *gorm.Statement) bool {
+	model, dest := reflect.ValueOf(stmt.Model), reflect.ValueOf(stmt.Dest)
+	return model.Kind() == reflect.Ptr && dest.Kind() == reflect.Ptr && model.Pointer() == dest.Pointer()
+}
+
+func nextVersion(version interface{}) (interface{}, bool) {
+	value := reflect.Indirect(reflect.ValueOf(version))
+	switch value.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return value.Int() + 1, true
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return value.Uint() + 1, true
+	}
+	return nil, false
+}
+
+// rowKey is the primary key value of row, the slice of the values for a
+// composite primary key.
+func rowKey(tx *gorm.DB, row reflect.Value) interface{} {
+	values := make([]interface{}, len(tx.Statement.Schema.PrimaryFields))
+	for i, field := range tx.Statement.Schema.PrimaryFields {
+		values[i], _ = field.ValueOf(tx.Statement.Context, row)
+	}
+	if len(values) == 1 {
+		return values[0]
+	}
+	return values
+}
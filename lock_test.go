@@ -0,0 +1,121 @@
+package pingorm
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+)
+
+type versionedBook struct {
+	ID      uint32 `gorm:"primaryKey"`
+	Title   string
+	Version uint32
+}
+
+func TestOptimisticLock(t *testing.T) {
+	repo := TypedRepo[versionedBook]{}
+
+	tests := []struct {
+		write    func(db *gorm.DB) (*versionedBook, error)
+		expModel *versionedBook
+		expKeys  []interface{}
+		expBooks []versionedBook
+	}{
+		// It should update the model read at the current version
+		{
+			write: func(db *gorm.DB) (*versionedBook, error) {
+				return repo.Update(db, versionedBook{ID: 1, Title: "Updated", Version: 1}, QueryOption{})
+			},
+			expModel: &versionedBook{ID: 1, Title: "Updated", Version: 2},
+			expBooks: []versionedBook{
+				{ID: 1, Title: "Updated", Version: 2},
+				{ID: 2, Title: "Book 2", Version: 1},
+				{ID: 3, Title: "Book 3", Version: 2},
+			},
+		},
+		// It should fail to update the model read at an older version
+		{
+			write: func(db *gorm.DB) (*versionedBook, error) {
+				return repo.Update(db, versionedBook{ID: 3, Title: "Updated", Version: 1}, QueryOption{})
+			},
+			expKeys: []interface{}{uint32(3)},
+			expBooks: []versionedBook{
+				{ID: 1, Title: "Book 1", Version: 1},
+				{ID: 2, Title: "Book 2", Version: 1},
+				{ID: 3, Title: "Book 3", Version: 2},
+			},
+		},
+		{
+			write: func(db *gorm.DB) (*versionedBook, error) {
+				return nil, repo.Updates(db, []uint32{1, 2}, map[string]interface{}{"title": "Updated", "version": 1}, QueryOption{})
+			},
+			expBooks: []versionedBook{
+				{ID: 1, Title: "Updated", Version: 2},
+				{ID: 2, Title: "Updated", Version: 2},
+				{ID: 3, Title: "Book 3", Version: 2},
+			},
+		},
+		// It should name the stale rows and update none
+		{
+			write: func(db *gorm.DB) (*versionedBook, error) {
+				return nil, repo.Updates(db, []uint32{1, 3}, map[string]interface{}{"title": "Updated", "version": 1}, QueryOption{})
+			},
+			expKeys: []interface{}{uint32(3)},
+			expBooks: []versionedBook{
+				{ID: 1, Title: "Book 1", Version: 1},
+				{ID: 2, Title: "Book 2", Version: 1},
+				{ID: 3, Title: "Book 3", Version: 2},
+			},
+		},
+		// It should increment the version of a write without any version
+		{
+			write: func(db *gorm.DB) (*versionedBook, error) {
+				return nil, repo.Updates(db, []uint32{1, 3}, versionedBook{Title: "Updated"}, QueryOption{})
+			},
+			expBooks: []versionedBook{
+				{ID: 1, Title: "Updated", Version: 2},
+				{ID: 2, Title: "Book 2", Version: 1},
+				{ID: 3, Title: "Updated", Version: 3},
+			},
+		},
+		{
+			write: func(db *gorm.DB) (*versionedBook, error) {
+				return nil, repo.Updates(db, []uint32{1, 3}, map[string]interface{}{"title": "Updated"}, QueryOption{})
+			},
+			expBooks: []versionedBook{
+				{ID: 1, Title: "Updated", Version: 2},
+				{ID: 2, Title: "Book 2", Version: 1},
+				{ID: 3, Title: "Updated", Version: 3},
+			},
+		},
+	}
+
+	for _, tc := range tests {
+		func() {
+			req := require.New(t)
+
+			db := openFixture(req, []interface{}{&versionedBook{}}, []versionedBook{
+				{ID: 1, Title: "Book 1", Version: 1},
+				{ID: 2, Title: "Book 2", Version: 1},
+				{ID: 3, Title: "Book 3", Version: 2},
+			})
+			defer closeDb(db)
+
+			model, err := tc.write(db)
+			if tc.expKeys != nil {
+				req.ErrorIs(err, ErrStaleObject)
+				var staleErr *StaleObjectError
+				req.ErrorAs(err, &staleErr)
+				req.Equal(tc.expKeys, staleErr.Keys)
+			} else {
+				req.Nil(err)
+				req.Equal(tc.expModel, model)
+			}
+
+			var books []versionedBook
+			req.Nil(db.Order("id").Find(&books).Error)
+			req.Equal(tc.expBooks, books)
+		}()
+	}
+}
@@ -0,0 +1,124 @@
+package pingorm
+
+import (
+	"fmt"
+	"reflect"
+
+	"gorm.io/gorm"
+)
+
+// GetByKeys gets the rows of sliceOfIDs as Get does, in the order of the keys
+// of sliceOfIDs rather than the database order, along with the keys no row
+// was found for. The key fields are to be selected, see
+// QueryOption.StrictKeys.
+func (repo Repo) GetByKeys(_db interface{}, sliceOfIDs interface{}, option QuerySelector) (sliceT interface{}, missingKeys []interface{}, err error) {
+	ptrSliceT, err := newPtrToSliceOf(repo.Model)
+	if err != nil {
+		return nil, nil, err
+	}
+	if missingKeys, err = getByKeys(_db.(*gorm.DB), repo.Model, sliceOfIDs, option, ptrSliceT); err != nil {
+		return nil, missingKeys, err
+	}
+	return reflect.ValueOf(ptrSliceT).Elem().Interface(), missingKeys, nil
+}
+
+// GetByKeys gets the rows of sliceOfIDs as Get does, in the order of the keys
+// of sliceOfIDs rather than the database order, along with the keys no row
+// was found for. The key fields are to be selected, see
+// QueryOption.StrictKeys.
+func (repo TypedRepo[T]) GetByKeys(db *gorm.DB, sliceOfIDs interface{}, option QuerySelector) ([]T, []interface{}, error) {
+	result := []T{}
+	missingKeys, err := getByKeys(db, new(T), sliceOfIDs, option, &result)
+	if err != nil {
+		return nil, missingKeys, err
+	}
+	return result, missingKeys, nil
+}
+
+// getByKeys finds into ptrToSlice the rows of sliceOfIDs ordered as their
+// keys are, the rows of the same key are left in the order found.
+func getByKeys(db *gorm.DB, model interface{}, sliceOfIDs interface{}, option QuerySelector, ptrToSlice interface{}) ([]interface{}, error) {
+	if option.GetPagination() != nil {
+		return nil, invalidArgument("GetByKeys does not paginate")
+	}
+	isEmpty, err := isEmptySlice(sliceOfIDs)
+	if err != nil || isEmpty {
+		return nil, err
+	}
+
+	modelSchema, err := parseSchema(db, model)
+	if err != nil {
+		return nil, err
+	}
+	keyCols := keyColumns(db, modelSchema, option)
+	_, args, err := buildWhereExprByKeys(db, modelSchema, sliceOfIDs, option)
+	if err != nil {
+		return nil, err
+	}
+
+	var keyRows [][]interface{}
+	switch values := args.(type) {
+	case [][]interface{}:
+		keyRows = values
+	case []interface{}:
+		for _, value := range values {
+			keyRows = append(keyRows, []interface{}{value})
+		}
+	}
+
+	_, findErr, err := findByKeyList(db, model, sliceOfIDs, option, ptrToSlice)
+	if err == nil {
+		err = findErr
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	ctx := db.Statement.Context
+	rows := reflect.ValueOf(ptrToSlice).Elem()
+	rowsByKey := map[string][]int{}
+	for i := 0; i < rows.Len(); i++ {
+		values := make([]interface{}, len(keyCols))
+		for j, column := range keyCols {
+			field := modelSchema.LookUpField(column)
+			if field == nil {
+				return nil, invalidArgument("key %s is not a field of %s", column, modelSchema.Name)
+			}
+			values[j], _ = field.ValueOf(ctx, reflect.Indirect(rows.Index(i)))
+		}
+		key := lookupKey(values)
+		rowsByKey[key] = append(rowsByKey[key], i)
+	}
+
+	var missingKeys []interface{}
+	ordered := reflect.MakeSlice(rows.Type(), 0, rows.Len())
+	for i, keyRow := range keyRows {
+		indexes, ok := rowsByKey[lookupKey(keyRow)]
+		if !ok {
+			missingKeys = append(missingKeys, reflect.ValueOf(sliceOfIDs).Index(i).Interface())
+			continue
+		}
+		for _, index := range indexes {
+			ordered = reflect.Append(ordered, rows.Index(index))
+		}
+	}
+	rows.Set(ordered)
+
+	if len(missingKeys) > 0 && option.IsStrictKeys() {
+		return missingKeys, &MissingKeysError{Keys: missingKeys}
+	}
+	return missingKeys, nil
+}
+
+// lookupKey compares the key values of a row to the ones of sliceOfIDs,
+// whatever the types of the values, i.e: uint32(1) is int(1).
+func lookupKey(values []interface{}) string {
+	texts := make([]string, len(values))
+	for i, value := range values {
+		if v := reflect.Indirect(reflect.ValueOf(value)); v.IsValid() {
+			value = v.Interface()
+		}
+		texts[i] = fmt.Sprint(value)
+	}
+	return fmt.Sprintf("%q", texts)
+}
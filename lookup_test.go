@@ -0,0 +1,83 @@
+package pingorm
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+)
+
+func TestGetByKeys(t *testing.T) {
+	tests := []struct {
+		get            func(db *gorm.DB) (interface{}, []interface{}, error)
+		expRows        interface{}
+		expMissingKeys []interface{}
+		expErr         error
+	}{
+		// It should order the rows as the keys and report the keys missing
+		{
+			get: func(db *gorm.DB) (interface{}, []interface{}, error) {
+				return TypedRepo[course]{}.GetByKeys(db, []string{"MA", "XX", "CS"}, QueryOption{})
+			},
+			expRows:        []course{{"MA", "Maths"}, {"CS", "Computer Science"}},
+			expMissingKeys: []interface{}{"XX"},
+		},
+		{
+			get: func(db *gorm.DB) (interface{}, []interface{}, error) {
+				return TypedRepo[enrollment]{}.GetByKeys(db, [][]interface{}{{2, 20}, {1, 20}, {3, 10}, {1, 10}}, QueryOption{})
+			},
+			expRows:        []enrollment{{2, 20, "A"}, {1, 20, "B"}, {1, 10, "C"}},
+			expMissingKeys: []interface{}{[]interface{}{3, 10}},
+		},
+		// It should get all the rows of a key in place of the key
+		{
+			get: func(db *gorm.DB) (interface{}, []interface{}, error) {
+				return Repo{Model: enrollment{}}.GetByKeys(db, []uint32{2, 1}, QueryOption{Keys: []string{"StudentID"}, Sorts: []Sort{{Field: "CourseID", Desc: true}}})
+			},
+			expRows: []enrollment{{2, 20, "A"}, {1, 20, "B"}, {1, 10, "C"}},
+		},
+		{
+			get: func(db *gorm.DB) (interface{}, []interface{}, error) {
+				return Repo{Model: enrollment{}}.GetByKeys(db, []uint32{1, 7}, QueryOption{Keys: []string{"StudentID"}})
+			},
+			expRows:        []enrollment{{1, 10, "C"}, {1, 20, "B"}},
+			expMissingKeys: []interface{}{uint32(7)},
+		},
+		// It should fail with the keys missing in strict mode
+		{
+			get: func(db *gorm.DB) (interface{}, []interface{}, error) {
+				return TypedRepo[course]{}.GetByKeys(db, []string{"XX", "CS", "YY"}, QueryOption{StrictKeys: true})
+			},
+			expMissingKeys: []interface{}{"XX", "YY"},
+			expErr:         &MissingKeysError{Keys: []interface{}{"XX", "YY"}},
+		},
+		{
+			get: func(db *gorm.DB) (interface{}, []interface{}, error) {
+				return TypedRepo[course]{}.GetByKeys(db, []string{"MA", "CS"}, QueryOption{StrictKeys: true})
+			},
+			expRows: []course{{"MA", "Maths"}, {"CS", "Computer Science"}},
+		},
+	}
+
+	for _, tc := range tests {
+		func() {
+			req := require.New(t)
+
+			db := openFixture(req, []interface{}{&enrollment{}, &course{}},
+				[]enrollment{{1, 10, "C"}, {1, 20, "B"}, {2, 20, "A"}},
+				[]course{{"CS", "Computer Science"}, {"MA", "Maths"}},
+			)
+			defer closeDb(db)
+
+			rows, missingKeys, err := tc.get(db)
+			req.Equal(tc.expMissingKeys, missingKeys)
+			if tc.expErr != nil {
+				req.ErrorIs(err, ErrNotFound)
+				req.Equal(tc.expErr, err)
+				return
+			}
+			req.Nil(err)
+			req.Equal(tc.expRows, rows)
+		}()
+	}
+}
@@ -54,6 +54,18 @@ type (
 		PreloadedFields   []string
 		UpdatesOnConflict map[string][]string
 		HardDelete        bool
+		Filters           []Filter
+		Pagination        *Pagination
+		Sorts             []Sort
+		PreloadSorts      map[string][]Sort
+		BatchSize         int
+		IncludeDeleted    bool
+		OnlyDeleted       bool
+		Cascades          map[string]CascadeRule
+		Upsert            *UpsertOption
+		Guard             *MutationGuard
+		KeyList           *KeyListOption
+		StrictKeys        bool
 	}
 
 	QuerySelector interface {
@@ -63,6 +75,18 @@ type (
 		GetUpdatesOnConflict() map[string][]string
 		IsHardDelete() bool
 		GetPreloadedFields() []string
+		GetFilters() []Filter
+		GetPagination() *Pagination
+		GetSorts() []Sort
+		GetPreloadSorts() map[string][]Sort
+		GetBatchSize() int
+		IsIncludeDeleted() bool
+		IsOnlyDeleted() bool
+		GetCascades() map[string]CascadeRule
+		GetUpsert() *UpsertOption
+		GetGuard() *MutationGuard
+		GetKeyList() *KeyListOption
+		IsStrictKeys() bool
 	}
 )
 
@@ -90,6 +114,54 @@ func (option QueryOption) GetPreloadedFields() []string {
 	return option.PreloadedFields
 }
 
+func (option QueryOption) GetFilters() []Filter {
+	return option.Filters
+}
+
+func (option QueryOption) GetPagination() *Pagination {
+	return option.Pagination
+}
+
+func (option QueryOption) GetSorts() []Sort {
+	return option.Sorts
+}
+
+func (option QueryOption) GetPreloadSorts() map[string][]Sort {
+	return option.PreloadSorts
+}
+
+func (option QueryOption) GetBatchSize() int {
+	return option.BatchSize
+}
+
+func (option QueryOption) IsIncludeDeleted() bool {
+	return option.IncludeDeleted
+}
+
+func (option QueryOption) IsOnlyDeleted() bool {
+	return option.OnlyDeleted
+}
+
+func (option QueryOption) GetCascades() map[string]CascadeRule {
+	return option.Cascades
+}
+
+func (option QueryOption) GetUpsert() *UpsertOption {
+	return option.Upsert
+}
+
+func (option QueryOption) GetGuard() *MutationGuard {
+	return option.Guard
+}
+
+func (option QueryOption) GetKeyList() *KeyListOption {
+	return option.KeyList
+}
+
+func (option QueryOption) IsStrictKeys() bool {
+	return option.StrictKeys
+}
+
 // Implement Authorable
 func (a Author) GetID() uint32 {
 	return a.ID
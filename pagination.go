@@ -0,0 +1,205 @@
+package pingorm
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"reflect"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"gorm.io/gorm/schema"
+)
+
+// ErrInvalidCursor is an InvalidArgumentError, it is ErrInvalidArgument to
+// errors.Is.
+var ErrInvalidCursor error = &InvalidArgumentError{Reason: "invalid pagination cursor"}
+
+type (
+	// Pagination pages by Limit and Offset, or by the sort keys when Keyset
+	// is set, in which case Cursor is the NextCursor of the previous page.
+	// The sort keys of a Keyset page are to be selected and not nullable.
+	Pagination struct {
+		Limit  int
+		Offset int
+		Keyset bool
+		Cursor string
+	}
+
+	PageInfo struct {
+		HasMore    bool
+		NextCursor string
+	}
+)
+
+// paginate limits db to one page, it fetches one extra row so that
+// buildPageInfo can tell whether more pages exist. Keyset pages follow
+// sortColumns, tie-broken by the primary keys.
+func paginate(db *gorm.DB, modelSchema *schema.Schema, option QuerySelector, sortColumns []sortColumn) (*gorm.DB, []sortColumn, error) {
+	pagination := option.GetPagination()
+	if pagination == nil {
+		return db, nil, nil
+	}
+	if pagination.Limit <= 0 {
+		return nil, nil, invalidArgument("pagination limit must be greater than 0")
+	}
+
+	db = db.Limit(pagination.Limit + 1)
+	if !pagination.Keyset {
+		return db.Offset(pagination.Offset), nil, nil
+	}
+
+	if len(modelSchema.PrimaryFields) == 0 {
+		return nil, nil, invalidArgument("keyset pagination requires a primary key")
+	}
+
+	columns := append([]sortColumn{}, sortColumns...)
+	for _, col := range sortColumns {
+		if col.nulls != "" {
+			return nil, nil, invalidArgument("keyset pagination does not support nulls ordering")
+		}
+	}
+
+	var tieBreakers []sortColumn
+	for _, field := range modelSchema.PrimaryFields {
+		isSorted := false
+		for _, col := range sortColumns {
+			isSorted = isSorted || col.field == field
+		}
+		if !isSorted {
+			tieBreakers = append(tieBreakers, sortColumn{field: field})
+		}
+	}
+	db = orderBy(db, tieBreakers)
+	columns = append(columns, tieBreakers...)
+
+	// The cursor holds the values of the last row, no row compares to a NULL
+	// value and a field left out of the row has its zero value
+	for _, col := range columns {
+		if isNullable(col.field) {
+			return nil, nil, invalidArgument("keyset pagination does not support the nullable field %s", col.field.Name)
+		}
+		if !isSelected(col.field, option.GetSelectedFields(), option.GetOmittedFields()) {
+			return nil, nil, invalidArgument("keyset pagination requires the field %s to be selected", col.field.Name)
+		}
+	}
+
+	if pagination.Cursor == "" {
+		return db, columns, nil
+	}
+
+	cursorValues, err := decodeCursor(pagination.Cursor, columns)
+	if err != nil {
+		return nil, nil, err
+	}
+	return db.Where(buildKeysetExpr(columns, cursorValues)), columns, nil
+}
+
+// isNullable reports whether the values of field may be NULL i.e: a pointer,
+// sql.NullString or gorm.DeletedAt.
+func isNullable(field *schema.Field) bool {
+	if field.FieldType.Kind() == reflect.Ptr {
+		return true
+	}
+	if field.FieldType.Kind() != reflect.Struct {
+		return false
+	}
+	valid, ok := field.FieldType.FieldByName("Valid")
+	return ok && valid.Type.Kind() == reflect.Bool
+}
+
+// isSelected reports whether field is in the rows found with the selected
+// and omitted fields, which are names or column names as gorm takes them.
+func isSelected(field *schema.Field, selectedFields []string, omittedFields []string) bool {
+	isNamed := func(names []string) bool {
+		for _, name := range names {
+			if name == "*" || name == field.Name || name == field.DBName {
+				return true
+			}
+		}
+		return false
+	}
+	return (len(selectedFields) == 0 || isNamed(selectedFields)) && !isNamed(omittedFields)
+}
+
+// buildKeysetExpr builds the condition of the rows after cursorValues
+// i.e: (a > ?) OR (a = ? AND b > ?)
+func buildKeysetExpr(columns []sortColumn, cursorValues []interface{}) clause.Expression {
+	orExprs := make([]clause.Expression, 0, len(columns))
+	for i, col := range columns {
+		andExprs := make([]clause.Expression, 0, i+1)
+		for j := 0; j < i; j++ {
+			andExprs = append(andExprs, clause.Eq{Column: clause.Column{Name: columns[j].field.DBName}, Value: cursorValues[j]})
+		}
+
+		column := clause.Column{Name: col.field.DBName}
+		if col.desc {
+			andExprs = append(andExprs, clause.Lt{Column: column, Value: cursorValues[i]})
+		} else {
+			andExprs = append(andExprs, clause.Gt{Column: column, Value: cursorValues[i]})
+		}
+		orExprs = append(orExprs, clause.And(andExprs...))
+	}
+	return clause.Or(orExprs...)
+}
+
+// buildPageInfo trims the extra row fetched by paginate off ptrToSlice.
+func buildPageInfo(ctx context.Context, ptrToSlice interface{}, pagination *Pagination, columns []sortColumn) (PageInfo, error) {
+	var pageInfo PageInfo
+	if pagination == nil {
+		return pageInfo, nil
+	}
+
+	sliceVal := reflect.ValueOf(ptrToSlice).Elem()
+	if sliceVal.Len() <= pagination.Limit {
+		return pageInfo, nil
+	}
+
+	pageInfo.HasMore = true
+	sliceVal.Set(sliceVal.Slice(0, pagination.Limit))
+
+	if pagination.Keyset {
+		lastRow := reflect.Indirect(sliceVal.Index(pagination.Limit - 1))
+		cursor, err := encodeCursor(ctx, lastRow, columns)
+		if err != nil {
+			return pageInfo, err
+		}
+		pageInfo.NextCursor = cursor
+	}
+	return pageInfo, nil
+}
+
+func encodeCursor(ctx context.Context, row reflect.Value, columns []sortColumn) (string, error) {
+	values := make([]interface{}, len(columns))
+	for i, col := range columns {
+		values[i], _ = col.field.ValueOf(ctx, row)
+	}
+
+	data, err := json.Marshal(values)
+	if err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(data), nil
+}
+
+func decodeCursor(cursor string, columns []sortColumn) ([]interface{}, error) {
+	data, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return nil, ErrInvalidCursor
+	}
+
+	var rawValues []json.RawMessage
+	if err := json.Unmarshal(data, &rawValues); err != nil || len(rawValues) != len(columns) {
+		return nil, ErrInvalidCursor
+	}
+
+	values := make([]interface{}, len(columns))
+	for i, col := range columns {
+		ptrToValue := reflect.New(col.field.FieldType)
+		if err := json.Unmarshal(rawValues[i], ptrToValue.Interface()); err != nil {
+			return nil, ErrInvalidCursor
+		}
+		values[i] = ptrToValue.Elem().Interface()
+	}
+	return values, nil
+}
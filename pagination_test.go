@@ -0,0 +1,90 @@
+package pingorm
+
+import (
+	"context"
+	"reflect"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm/clause"
+	"gorm.io/gorm/schema"
+)
+
+func TestCursor(t *testing.T) {
+	tests := []struct {
+		row       interface{}
+		fields    []string
+		cursor    string
+		expValues []interface{}
+		expErr    error
+	}{
+		{
+			row:       Author{ID: 7, Name: "Henglong"},
+			fields:    []string{"ID"},
+			expValues: []interface{}{uint32(7)},
+		},
+		{
+			row:       Book{ID: 3, Title: "Pingorm"},
+			fields:    []string{"Title", "ID"},
+			expValues: []interface{}{"Pingorm", uint32(3)},
+		},
+		{
+			fields: []string{"ID"},
+			cursor: "not a cursor",
+			expErr: ErrInvalidCursor,
+		},
+		{
+			fields: []string{"Title", "ID"},
+			cursor: "WzNd",
+			expErr: ErrInvalidCursor,
+		},
+	}
+
+	for _, tc := range tests {
+		req := require.New(t)
+
+		model := tc.row
+		if model == nil {
+			model = Book{}
+		}
+		modelSchema, err := schema.Parse(model, &sync.Map{}, schema.NamingStrategy{SingularTable: true})
+		req.Nil(err)
+
+		var columns []sortColumn
+		for _, name := range tc.fields {
+			columns = append(columns, sortColumn{field: modelSchema.LookUpField(name)})
+		}
+
+		cursor := tc.cursor
+		if tc.row != nil {
+			cursor, err = encodeCursor(context.Background(), reflect.ValueOf(tc.row), columns)
+			req.Nil(err)
+		}
+
+		values, err := decodeCursor(cursor, columns)
+		req.Equal(tc.expErr, err)
+		req.Equal(tc.expValues, values)
+	}
+}
+
+func TestBuildKeysetExpr(t *testing.T) {
+	req := require.New(t)
+
+	modelSchema, err := schema.Parse(&Book{}, &sync.Map{}, schema.NamingStrategy{SingularTable: true})
+	req.Nil(err)
+
+	columns := []sortColumn{
+		{field: modelSchema.LookUpField("Title"), desc: true},
+		{field: modelSchema.LookUpField("ID")},
+	}
+
+	expr := buildKeysetExpr(columns, []interface{}{"Pingorm", uint32(3)})
+	req.Equal(clause.Or(
+		clause.And(clause.Lt{Column: clause.Column{Name: "title"}, Value: "Pingorm"}),
+		clause.And(
+			clause.Eq{Column: clause.Column{Name: "title"}, Value: "Pingorm"},
+			clause.Gt{Column: clause.Column{Name: "id"}, Value: uint32(3)},
+		),
+	), expr)
+}
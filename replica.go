@@ -0,0 +1,265 @@
+package pingorm
+
+import (
+	"context"
+	"math/rand"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+type ReplicaPolicy string
+
+const (
+	ReplicaRoundRobin ReplicaPolicy = "round_robin"
+	ReplicaWeighted   ReplicaPolicy = "weighted"
+)
+
+// Replica is a read replica of DbOption, Weight is its share of the reads
+// under ReplicaWeighted and defaults to 1.
+type Replica struct {
+	ConString string
+	Weight    int
+}
+
+const (
+	replicasPluginName                = "app:replicas"
+	usePrimaryKey                     = "app:use_primary"
+	defaultReplicaHealthCheckInterval = 10 * time.Second
+	replicaPingTimeout                = time.Second
+)
+
+// UsePrimary sends the reads of db to the primary, i.e: to read your own
+// writes made outside a transaction. The reads inside a transaction always
+// go to the primary.
+func UsePrimary(db *gorm.DB) *gorm.DB {
+	return db.Set(usePrimaryKey, true)
+}
+
+type (
+	replicaSet struct {
+		replicas            []*replica
+		policy              ReplicaPolicy
+		healthCheckInterval time.Duration
+		next                uint64
+	}
+
+	// replica is opened by its first health check, a replica unreachable
+	// then is retried on the next check rather than failing OpenDbOption.
+	replica struct {
+		conString string
+		option    DbOption
+		weight    int
+		// open opens the replica, openDb but in the tests.
+		open func(conString string, option DbOption) (*gorm.DB, error)
+
+		mu        sync.Mutex
+		db        *gorm.DB
+		healthy   bool
+		checking  bool
+		opening   bool
+		closed    bool
+		checkedAt time.Time
+	}
+)
+
+func newReplicaSet(option DbOption) *replicaSet {
+	replicas := &replicaSet{
+		policy:              option.ReplicaPolicy,
+		healthCheckInterval: option.ReplicaHealthCheckInterval,
+	}
+	if replicas.policy == "" {
+		replicas.policy = ReplicaRoundRobin
+	}
+	if replicas.healthCheckInterval <= 0 {
+		replicas.healthCheckInterval = defaultReplicaHealthCheckInterval
+	}
+
+	// The reads wait for the health checks, they must not wait for retries
+	replicaOption := option
+	replicaOption.ConnectRetries = 0
+
+	for _, r := range option.Replicas {
+		weight := r.Weight
+		if weight <= 0 {
+			weight = 1
+		}
+		replicas.replicas = append(replicas.replicas, &replica{conString: r.ConString, option: replicaOption, weight: weight, open: openDb})
+	}
+	return replicas
+}
+
+func (r *replicaSet) Name() string {
+	return replicasPluginName
+}
+
+func (r *replicaSet) Initialize(db *gorm.DB) error {
+	if err := db.Callback().Query().Before("gorm:query").Register("app:replica_query", r.routeRead); err != nil {
+		return err
+	}
+	if err := db.Callback().Row().Before("gorm:row").Register("app:replica_row", r.routeRead); err != nil {
+		return err
+	}
+	if err := db.Callback().Create().Before("gorm:begin_transaction").Register("app:replica_create", r.routeWrite); err != nil {
+		return err
+	}
+	if err := db.Callback().Update().Before("gorm:begin_transaction").Register("app:replica_update", r.routeWrite); err != nil {
+		return err
+	}
+	if err := db.Callback().Delete().Before("gorm:begin_transaction").Register("app:replica_delete", r.routeWrite); err != nil {
+		return err
+	}
+	return db.Callback().Raw().Before("gorm:raw").Register("app:replica_raw", r.routeWrite)
+}
+
+// routeRead sends the read of tx to a healthy replica unless tx runs in a
+// transaction, the primary takes it when no replica is healthy. Raw SQL stays
+// on the primary as it may as well write.
+func (r *replicaSet) routeRead(tx *gorm.DB) {
+	if usePrimary, _ := tx.Get(usePrimaryKey); usePrimary == true {
+		r.routeWrite(tx)
+		return
+	}
+
+	// A transaction or a statement already routed to a replica
+	if tx.Statement.ConnPool != tx.ConnPool || tx.Statement.SQL.Len() > 0 {
+		return
+	}
+
+	if connPool := r.pick(); connPool != nil {
+		tx.Statement.ConnPool = connPool
+	}
+}
+
+// routeWrite sends tx back to the primary when a previous read of its
+// statement went to a replica.
+func (r *replicaSet) routeWrite(tx *gorm.DB) {
+	for _, replica := range r.replicas {
+		if connPool := replica.connPool(); connPool != nil && tx.Statement.ConnPool == connPool {
+			tx.Statement.ConnPool = tx.ConnPool
+			return
+		}
+	}
+}
+
+func (r *replicaSet) pick() gorm.ConnPool {
+	healthy := make([]*replica, 0, len(r.replicas))
+	totalWeight := 0
+	for _, replica := range r.replicas {
+		if replica.isHealthy(r.healthCheckInterval) {
+			healthy = append(healthy, replica)
+			totalWeight += replica.weight
+		}
+	}
+	if len(healthy) == 0 {
+		return nil
+	}
+
+	if r.policy == ReplicaWeighted {
+		n := rand.Intn(totalWeight)
+		for _, replica := range healthy {
+			if n < replica.weight {
+				return replica.connPool()
+			}
+			n -= replica.weight
+		}
+	}
+
+	next := atomic.AddUint64(&r.next, 1) - 1
+	return healthy[next%uint64(len(healthy))].connPool()
+}
+
+func (r *replicaSet) close() {
+	for _, replica := range r.replicas {
+		replica.mu.Lock()
+		closeDb(replica.db)
+		replica.db = nil
+		replica.closed = true
+		replica.mu.Unlock()
+	}
+}
+
+func (r *replica) connPool() gorm.ConnPool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.db == nil {
+		return nil
+	}
+	return r.db.ConnPool
+}
+
+// isHealthy checks the replica at most once per interval. Only the read the
+// check is due to waits for it, up to replicaPingTimeout, the reads meanwhile
+// take the health known so far.
+func (r *replica) isHealthy(interval time.Duration) bool {
+	r.mu.Lock()
+	if r.checking || time.Since(r.checkedAt) < interval {
+		healthy := r.healthy
+		r.mu.Unlock()
+		return healthy
+	}
+	r.checking = true
+	db := r.db
+	r.mu.Unlock()
+
+	// The lock is not held for the network round trips of the check
+	err := r.check(db)
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.healthy = err == nil
+	r.checking = false
+	r.checkedAt = time.Now()
+	return r.healthy
+}
+
+// check pings db, or opens the replica when db is nil. An open taking longer
+// than replicaPingTimeout goes on in the background for the next check.
+func (r *replica) check(db *gorm.DB) error {
+	if db != nil {
+		sqlDB, err := db.DB()
+		if err != nil {
+			return err
+		}
+		ctx, cancel := context.WithTimeout(context.Background(), replicaPingTimeout)
+		defer cancel()
+		return sqlDB.PingContext(ctx)
+	}
+
+	r.mu.Lock()
+	if r.opening {
+		r.mu.Unlock()
+		return context.DeadlineExceeded
+	}
+	r.opening = true
+	r.mu.Unlock()
+
+	opened := make(chan error, 1)
+	go func() {
+		db, err := r.open(r.conString, r.option)
+
+		r.mu.Lock()
+		r.opening = false
+		if err == nil {
+			if r.closed {
+				closeDb(db)
+			} else {
+				r.db = db
+			}
+		}
+		r.mu.Unlock()
+		opened <- err
+	}()
+
+	timer := time.NewTimer(replicaPingTimeout)
+	defer timer.Stop()
+	select {
+	case err := <-opened:
+		return err
+	case <-timer.C:
+		return context.DeadlineExceeded
+	}
+}
@@ -0,0 +1,170 @@
+package pingorm
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+)
+
+func TestReplicas(t *testing.T) {
+
+	const (
+		primaryConString  = "sqlite://file:pingorm_primary?mode=memory&cache=shared"
+		replicaAConString = "sqlite://file:pingorm_replica_a?mode=memory&cache=shared"
+		replicaBConString = "sqlite://file:pingorm_replica_b?mode=memory&cache=shared"
+		downConString     = "sqlite://file:/nonexistent/pingorm.db?mode=ro"
+	)
+
+	// Each database holds one author named after it
+	seeds := map[string]string{
+		primaryConString:  "primary",
+		replicaAConString: "replica_a",
+		replicaBConString: "replica_b",
+	}
+	for conString, name := range seeds {
+		seedDb, err := OpenDb(conString)
+		require.Nil(t, err)
+		defer closeDb(seedDb)
+
+		require.Nil(t, seedDb.AutoMigrate(&Author{}))
+		require.Nil(t, seedDb.Create(&Author{ID: 1, Name: name}).Error)
+	}
+
+	tests := []struct {
+		replicas   []Replica
+		policy     ReplicaPolicy
+		usePrimary bool
+		inTx       bool
+		expNames   []string
+	}{
+		// It should take turns between the replicas
+		{
+			replicas: []Replica{{ConString: replicaAConString}, {ConString: replicaBConString}},
+			expNames: []string{"replica_a", "replica_b", "replica_a"},
+		},
+		// It should read from the primary on demand
+		{
+			replicas:   []Replica{{ConString: replicaAConString}, {ConString: replicaBConString}},
+			usePrimary: true,
+			expNames:   []string{"primary", "primary", "primary"},
+		},
+		// It should read from the primary inside a transaction
+		{
+			replicas: []Replica{{ConString: replicaAConString}, {ConString: replicaBConString}},
+			inTx:     true,
+			expNames: []string{"primary", "primary", "primary"},
+		},
+		// It should skip the unhealthy replica
+		{
+			replicas: []Replica{{ConString: downConString}, {ConString: replicaBConString}},
+			expNames: []string{"replica_b", "replica_b", "replica_b"},
+		},
+		// It should fall back to the primary when no replica is healthy
+		{
+			replicas: []Replica{{ConString: downConString}},
+			expNames: []string{"primary", "primary", "primary"},
+		},
+		{
+			replicas: []Replica{{ConString: downConString, Weight: 5}, {ConString: replicaAConString, Weight: 1}},
+			policy:   ReplicaWeighted,
+			expNames: []string{"replica_a", "replica_a", "replica_a"},
+		},
+	}
+
+	for _, tc := range tests {
+		func() {
+			req := require.New(t)
+
+			db, err := OpenDbOption(primaryConString, DbOption{Replicas: tc.replicas, ReplicaPolicy: tc.policy})
+			req.Nil(err)
+			defer closeDb(db)
+
+			if tc.usePrimary {
+				db = UsePrimary(db)
+			}
+
+			repo := TypedRepo[Author]{}
+			var names []string
+			read := func(tx *gorm.DB) error {
+				for range tc.expNames {
+					authors, err := repo.Get(tx, []uint32{1}, QueryOption{})
+					if err != nil {
+						return err
+					}
+					req.Len(authors, 1)
+					names = append(names, authors[0].Name)
+				}
+				return nil
+			}
+
+			if tc.inTx {
+				req.Nil(WithTx(context.Background(), db, read))
+			} else {
+				req.Nil(read(db))
+			}
+			req.Equal(tc.expNames, names)
+		}()
+	}
+}
+
+func TestReplicasWrite(t *testing.T) {
+	req := require.New(t)
+
+	const (
+		primaryConString = "sqlite://file:pingorm_write_primary?mode=memory&cache=shared"
+		replicaConString = "sqlite://file:pingorm_write_replica?mode=memory&cache=shared"
+	)
+
+	replicaDb, err := OpenDb(replicaConString)
+	req.Nil(err)
+	defer closeDb(replicaDb)
+	req.Nil(replicaDb.AutoMigrate(&Author{}))
+
+	db, err := OpenDbOption(primaryConString, DbOption{Replicas: []Replica{{ConString: replicaConString}}})
+	req.Nil(err)
+	defer closeDb(db)
+	req.Nil(db.AutoMigrate(&Author{}))
+
+	// A read routes the statement to the replica, the write after it must
+	// still go to the primary.
+	stmt := db.Model(&Author{})
+	var total int64
+	req.Nil(stmt.Count(&total).Error)
+	req.Nil(stmt.Create(&Author{ID: 1, Name: "Henglong"}).Error)
+
+	var primaryTotal, replicaTotal int64
+	req.Nil(UsePrimary(db).Model(&Author{}).Count(&primaryTotal).Error)
+	req.Nil(replicaDb.Model(&Author{}).Count(&replicaTotal).Error)
+	req.Equal(int64(1), primaryTotal)
+	req.Equal(int64(0), replicaTotal)
+}
+
+func TestReplicaHealthCheckTimeout(t *testing.T) {
+	req := require.New(t)
+
+	// The open hangs until it is released
+	opening, release := make(chan struct{}), make(chan struct{})
+	r := &replica{weight: 1, open: func(string, DbOption) (*gorm.DB, error) {
+		close(opening)
+		<-release
+		return openDb(dbConString, DbOption{})
+	}}
+	defer (&replicaSet{replicas: []*replica{r}}).close()
+
+	checked := make(chan bool)
+	go func() { checked <- r.isHealthy(0) }()
+	<-opening
+
+	// It should not hold the reads behind the check in progress
+	req.False(r.isHealthy(0))
+
+	// It should give up on the open past the ping timeout
+	req.False(<-checked)
+
+	// It should take the replica opened in the background on a later check
+	close(release)
+	req.Eventually(func() bool { return r.isHealthy(0) }, time.Minute, 10*time.Millisecond)
+}
@@ -0,0 +1,165 @@
+package pingorm
+
+import (
+	"context"
+	"reflect"
+
+	"gorm.io/gorm"
+)
+
+// TypedRepo is the type-safe counterpart of Repo, T is the model struct type
+// i.e: TypedRepo[Author]{}
+type TypedRepo[T any] struct{}
+
+func (repo TypedRepo[T]) Create(db *gorm.DB, model T, option QuerySelector) (*T, error) {
+	if err := create(db, &model, option); err != nil {
+		return nil, err
+	}
+	return &model, nil
+}
+
+func (repo TypedRepo[T]) Update(db *gorm.DB, model T, option QuerySelector) (*T, error) {
+	if err := update(db, &model, option); err != nil {
+		return nil, err
+	}
+	return &model, nil
+}
+
+func (repo TypedRepo[T]) Upsert(db *gorm.DB, models []T, option QuerySelector) ([]T, error) {
+	if len(models) == 0 {
+		return nil, invalidArgument("empty slices")
+	}
+
+	if err := upsert(db, &models, option); err != nil {
+		return nil, err
+	}
+	return models, nil
+}
+
+func (repo TypedRepo[T]) Delete(db *gorm.DB, sliceOfIDs interface{}, option QuerySelector) error {
+	_, err := repo.DeleteResult(db, sliceOfIDs, option)
+	return err
+}
+
+// DeleteResult deletes as Delete does and reports the rows deleted, see
+// QueryOption.Guard.
+func (repo TypedRepo[T]) DeleteResult(db *gorm.DB, sliceOfIDs interface{}, option QuerySelector) (MutationResult, error) {
+	isEmpty, err := isEmptySlice(sliceOfIDs)
+	if err != nil {
+		return MutationResult{}, err
+	}
+
+	return guardMutation(db, option, isEmpty, func(tx *gorm.DB) (int64, error) {
+		return deleteByKeyList(tx, new(T), sliceOfIDs, option)
+	})
+}
+
+func (repo TypedRepo[T]) Updates(db *gorm.DB, sliceOfIDs interface{}, values interface{}, option QuerySelector) error {
+	_, err := repo.UpdatesResult(db, sliceOfIDs, values, option)
+	return err
+}
+
+// UpdatesResult updates as Updates does and reports the rows updated, see
+// QueryOption.Guard.
+func (repo TypedRepo[T]) UpdatesResult(db *gorm.DB, sliceOfIDs interface{}, values interface{}, option QuerySelector) (MutationResult, error) {
+	isEmpty, err := isEmptySlice(sliceOfIDs)
+	if err != nil {
+		return MutationResult{}, err
+	}
+
+	return guardMutation(db, option, isEmpty, func(tx *gorm.DB) (int64, error) {
+		return updatesByKeys(tx, new(T), sliceOfIDs, values, option)
+	})
+}
+
+func (repo TypedRepo[T]) Get(db *gorm.DB, sliceOfIDs interface{}, option QuerySelector) ([]T, error) {
+	result, _, err := repo.GetPage(db, sliceOfIDs, option)
+	return result, err
+}
+
+// GetPage gets one page of the rows as Get does along with the info of
+// the next page, see QueryOption.Pagination.
+func (repo TypedRepo[T]) GetPage(db *gorm.DB, sliceOfIDs interface{}, option QuerySelector) ([]T, PageInfo, error) {
+	result := []T{}
+	keysetColumns, findErr, err := findByKeyList(db, new(T), sliceOfIDs, option, &result)
+	if err == nil {
+		err = findErr
+	}
+	if err != nil {
+		return nil, PageInfo{}, err
+	}
+
+	pageInfo, err := buildPageInfo(db.Statement.Context, &result, option.GetPagination(), keysetColumns)
+	if err != nil {
+		return nil, PageInfo{}, err
+	}
+	return result, pageInfo, nil
+}
+
+func (repo TypedRepo[T]) CreateContext(ctx context.Context, db *gorm.DB, model T, option QuerySelector) (*T, error) {
+	db, err := dbWithContext(ctx, db)
+	if err != nil {
+		return nil, err
+	}
+
+	got, err := repo.Create(db, model, option)
+	return got, contextError(ctx, err)
+}
+
+func (repo TypedRepo[T]) UpdateContext(ctx context.Context, db *gorm.DB, model T, option QuerySelector) (*T, error) {
+	db, err := dbWithContext(ctx, db)
+	if err != nil {
+		return nil, err
+	}
+
+	got, err := repo.Update(db, model, option)
+	return got, contextError(ctx, err)
+}
+
+func (repo TypedRepo[T]) UpsertContext(ctx context.Context, db *gorm.DB, models []T, option QuerySelector) ([]T, error) {
+	db, err := dbWithContext(ctx, db)
+	if err != nil {
+		return nil, err
+	}
+
+	got, err := repo.Upsert(db, models, option)
+	return got, contextError(ctx, err)
+}
+
+func (repo TypedRepo[T]) DeleteContext(ctx context.Context, db *gorm.DB, sliceOfIDs interface{}, option QuerySelector) error {
+	db, err := dbWithContext(ctx, db)
+	if err != nil {
+		return err
+	}
+
+	return contextError(ctx, repo.Delete(db, sliceOfIDs, option))
+}
+
+func (repo TypedRepo[T]) UpdatesContext(ctx context.Context, db *gorm.DB, sliceOfIDs interface{}, values interface{}, option QuerySelector) error {
+	db, err := dbWithContext(ctx, db)
+	if err != nil {
+		return err
+	}
+
+	return contextError(ctx, repo.Updates(db, sliceOfIDs, values, option))
+}
+
+func (repo TypedRepo[T]) GetContext(ctx context.Context, db *gorm.DB, sliceOfIDs interface{}, option QuerySelector) ([]T, error) {
+	db, err := dbWithContext(ctx, db)
+	if err != nil {
+		return nil, err
+	}
+
+	got, err := repo.Get(db, sliceOfIDs, option)
+	return got, contextError(ctx, err)
+}
+
+func isEmptySlice(slice interface{}) (bool, error) {
+	if slice == nil {
+		return true, nil
+	}
+	if err := assertSliceType(reflect.TypeOf(slice)); err != nil {
+		return false, err
+	}
+	return reflect.ValueOf(slice).Len() == 0, nil
+}
@@ -0,0 +1,501 @@
+package pingorm
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+)
+
+func TestTypedRepoCreate(t *testing.T) {
+
+	tests := []struct {
+		seeds       []interface{}
+		input       Author
+		queryParams QueryOption
+		expGot      *Author
+		expDbAuthor []Author
+	}{
+		// It should create Author and return the typed pointer
+		{
+			seeds: []interface{}{
+				&Author{
+					ID:   1,
+					Name: "Henglong",
+					Sex:  "Male",
+				},
+			},
+			input: Author{
+				Name: "Vicheka",
+				Sex:  "Male",
+			},
+			expGot: &Author{
+				ID:   2,
+				Name: "Vicheka",
+				Sex:  "Male",
+			},
+			expDbAuthor: []Author{
+				{
+					ID:   1,
+					Name: "Henglong",
+					Sex:  "Male",
+				},
+				{
+					ID:   2,
+					Name: "Vicheka",
+					Sex:  "Male",
+				},
+			},
+		},
+	}
+
+	for _, tc := range tests {
+		func() {
+			req := require.New(t)
+
+			cleanTables()
+
+			db, err := OpenDb(dbConString)
+			req.Nil(err)
+			db = db.Debug()
+
+			for _, seed := range tc.seeds {
+				err = db.Create(seed).Error
+				req.Nil(err)
+			}
+
+			got, err := TypedRepo[Author]{}.Create(db, tc.input, tc.queryParams)
+			req.Nil(err)
+			req.Equal(tc.expGot, got)
+
+			var dbAuthors []Author
+			db.Model(&Author{}).Select("ID", "Name", "Sex").Find(&dbAuthors)
+			req.Equal(tc.expDbAuthor, dbAuthors)
+		}()
+	}
+}
+
+func TestTypedRepoGet(t *testing.T) {
+
+	tests := []struct {
+		seeds       []interface{}
+		inputIDs    interface{}
+		queryParams QueryOption
+		expGot      []Author
+		expErr      error
+	}{
+		// Get Author where ID = 1
+		{
+			seeds: []interface{}{
+				&Author{
+					ID:   1,
+					Name: "Henglong",
+					Sex:  "Male",
+				},
+				&Author{
+					ID:   2,
+					Name: "Vicheka",
+					Sex:  "Male",
+				},
+			},
+			inputIDs:    []uint32{1},
+			queryParams: QueryOption{SelectedFields: []string{"ID", "Name"}},
+			expGot: []Author{
+				{
+					ID:   1,
+					Name: "Henglong",
+				},
+			},
+		},
+
+		// It should return an empty slice when no row matches
+		{
+			seeds: []interface{}{
+				&Author{
+					ID:   1,
+					Name: "Henglong",
+				},
+			},
+			inputIDs:    []uint32{2},
+			queryParams: QueryOption{SelectedFields: []string{"ID", "Name"}},
+			expGot:      []Author{},
+		},
+
+		// Get Author by composite keys
+		{
+			seeds: []interface{}{
+				&Author{
+					ID:   1,
+					Name: "Henglong",
+				},
+				&Author{
+					ID:   2,
+					Name: "Vicheka",
+				},
+			},
+			inputIDs:    [][]interface{}{{2, "Vicheka"}},
+			queryParams: QueryOption{Keys: []string{"ID", "Name"}, SelectedFields: []string{"ID", "Name"}},
+			expGot: []Author{
+				{
+					ID:   2,
+					Name: "Vicheka",
+				},
+			},
+		},
+
+		// Get Author by filters only
+		{
+			seeds: []interface{}{
+				&Author{
+					ID:   1,
+					Name: "Henglong",
+					Sex:  "Male",
+				},
+				&Author{
+					ID:   2,
+					Name: "Vicheka",
+					Sex:  "Female",
+				},
+				&Author{
+					ID:   3,
+					Name: "Vichheka",
+					Sex:  "Male",
+				},
+			},
+			inputIDs: nil,
+			queryParams: QueryOption{
+				SelectedFields: []string{"ID", "Name"},
+				Filters:        []Filter{Like("Name", "Vic%"), Or(Eq("Sex", "Male"), IsNull("Dob"))},
+			},
+			expGot: []Author{
+				{
+					ID:   2,
+					Name: "Vicheka",
+				},
+				{
+					ID:   3,
+					Name: "Vichheka",
+				},
+			},
+		},
+
+		{
+			inputIDs:    [][]interface{}{{1, "Henglong"}, {2}},
+			queryParams: QueryOption{Keys: []string{"ID", "Name"}},
+			expErr:      &InvalidArgumentError{Reason: "key length 2 requires value length 2"},
+		},
+	}
+
+	for _, tc := range tests {
+		func() {
+			req := require.New(t)
+
+			cleanTables()
+
+			db, err := OpenDb(dbConString)
+			req.Nil(err)
+			db = db.Debug()
+
+			for _, seed := range tc.seeds {
+				err = db.Create(seed).Error
+				req.Nil(err)
+			}
+
+			got, errGet := TypedRepo[Author]{}.Get(db, tc.inputIDs, tc.queryParams)
+			req.Equal(tc.expErr, errGet)
+			req.Equal(tc.expGot, got)
+		}()
+	}
+}
+
+func TestTypedRepoDeleteAndUpdates(t *testing.T) {
+
+	tests := []struct {
+		seeds       []interface{}
+		deletedIDs  interface{}
+		updatedIDs  interface{}
+		inputValues interface{}
+		queryParams QueryOption
+		expDbAuthor []Author
+		expErr      error
+	}{
+		// It should hard delete ID 1 and update ID 2
+		{
+			seeds: []interface{}{
+				&Author{
+					ID:   1,
+					Name: "Henglong",
+					Sex:  "Male",
+				},
+				&Author{
+					ID:   2,
+					Name: "Vicheka",
+					Sex:  "Male",
+				},
+			},
+			deletedIDs:  []uint32{1},
+			updatedIDs:  []uint32{2},
+			inputValues: map[string]interface{}{"name": "Vicheka-Updated"},
+			queryParams: QueryOption{HardDelete: true},
+			expDbAuthor: []Author{
+				{
+					ID:   2,
+					Name: "Vicheka-Updated",
+					Sex:  "Male",
+				},
+			},
+		},
+
+		// It should return an error instead of panicking on a non slice key
+		{
+			deletedIDs:  uint32(1),
+			updatedIDs:  []uint32{},
+			expDbAuthor: []Author{},
+			expErr:      &InvalidArgumentError{Reason: "value must be a kind of slice"},
+		},
+	}
+
+	for _, tc := range tests {
+		func() {
+			req := require.New(t)
+
+			cleanTables()
+
+			db, err := OpenDb(dbConString)
+			req.Nil(err)
+			db = db.Debug()
+
+			for _, seed := range tc.seeds {
+				err = db.Create(seed).Error
+				req.Nil(err)
+			}
+
+			repo := TypedRepo[Author]{}
+			req.Equal(tc.expErr, repo.Delete(db, tc.deletedIDs, tc.queryParams))
+			req.Nil(repo.Updates(db, tc.updatedIDs, tc.inputValues, tc.queryParams))
+
+			var dbAuthors []Author
+			db.Model(&Author{}).Unscoped().Select("ID", "Name", "Sex").Find(&dbAuthors)
+			req.Equal(tc.expDbAuthor, dbAuthors)
+		}()
+	}
+}
+
+func TestTypedRepoContext(t *testing.T) {
+
+	canceledCtx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	expiredCtx, cancelExpired := context.WithTimeout(context.Background(), -time.Second)
+	defer cancelExpired()
+
+	tests := []struct {
+		ctx    context.Context
+		expErr error
+	}{
+		{
+			ctx:    context.Background(),
+			expErr: nil,
+		},
+		{
+			ctx:    canceledCtx,
+			expErr: context.Canceled,
+		},
+		{
+			ctx:    expiredCtx,
+			expErr: context.DeadlineExceeded,
+		},
+	}
+
+	for _, tc := range tests {
+		func() {
+			req := require.New(t)
+
+			cleanTables()
+
+			db, err := OpenDb(dbConString)
+			req.Nil(err)
+			db = db.Debug()
+
+			repo := TypedRepo[Author]{}
+			_, errCreate := repo.CreateContext(tc.ctx, db, Author{Name: "Henglong"}, QueryOption{})
+			req.Equal(tc.expErr, errCreate)
+
+			_, errGet := repo.GetContext(tc.ctx, db, []uint32{1}, QueryOption{})
+			req.Equal(tc.expErr, errGet)
+
+			errUpdates := repo.UpdatesContext(tc.ctx, db, []uint32{1}, map[string]interface{}{"sex": "Male"}, QueryOption{})
+			req.Equal(tc.expErr, errUpdates)
+
+			errDelete := repo.DeleteContext(tc.ctx, db, []uint32{1}, QueryOption{})
+			req.Equal(tc.expErr, errDelete)
+		}()
+	}
+}
+
+func TestTypedRepoGetPage(t *testing.T) {
+
+	seeds := []interface{}{
+		&Author{ID: 1, Name: "Henglong"},
+		&Author{ID: 2, Name: "Vicheka"},
+		&Author{ID: 3, Name: "Vichheka"},
+	}
+
+	tests := []struct {
+		pagination  *Pagination
+		expGot      []Author
+		expPageInfo PageInfo
+		expErr      error
+	}{
+		// It should get the first page by limit and offset
+		{
+			pagination: &Pagination{Limit: 2},
+			expGot: []Author{
+				{ID: 1, Name: "Henglong"},
+				{ID: 2, Name: "Vicheka"},
+			},
+			expPageInfo: PageInfo{HasMore: true},
+		},
+		// It should get the last page by limit and offset
+		{
+			pagination: &Pagination{Limit: 2, Offset: 2},
+			expGot: []Author{
+				{ID: 3, Name: "Vichheka"},
+			},
+			expPageInfo: PageInfo{},
+		},
+		// It should get the first keyset page with the cursor of the next page
+		{
+			pagination: &Pagination{Limit: 1, Keyset: true},
+			expGot: []Author{
+				{ID: 1, Name: "Henglong"},
+			},
+			expPageInfo: PageInfo{HasMore: true, NextCursor: "WzFd"},
+		},
+		// It should get the keyset page after the cursor
+		{
+			pagination: &Pagination{Limit: 1, Keyset: true, Cursor: "WzJd"},
+			expGot: []Author{
+				{ID: 3, Name: "Vichheka"},
+			},
+			expPageInfo: PageInfo{},
+		},
+		{
+			pagination: &Pagination{Limit: 1, Keyset: true, Cursor: "WzJd,"},
+			expErr:     ErrInvalidCursor,
+		},
+		{
+			pagination: &Pagination{},
+			expErr:     &InvalidArgumentError{Reason: "pagination limit must be greater than 0"},
+		},
+	}
+
+	for _, tc := range tests {
+		func() {
+			req := require.New(t)
+
+			cleanTables()
+
+			db, err := OpenDb(dbConString)
+			req.Nil(err)
+			db = db.Debug()
+
+			for _, seed := range seeds {
+				err = db.Create(seed).Error
+				req.Nil(err)
+			}
+
+			got, pageInfo, errGet := TypedRepo[Author]{}.GetPage(db, nil, QueryOption{
+				SelectedFields: []string{"ID", "Name"},
+				Pagination:     tc.pagination,
+			})
+			req.Equal(tc.expErr, errGet)
+			if tc.expErr == nil {
+				req.Equal(tc.expGot, got)
+			}
+			req.Equal(tc.expPageInfo, pageInfo)
+		}()
+	}
+}
+
+type enrollment struct {
+	StudentID uint32 `gorm:"primaryKey"`
+	CourseID  uint32 `gorm:"primaryKey"`
+	Grade     string
+}
+
+type course struct {
+	Code  string `gorm:"primaryKey"`
+	Title string
+}
+
+func TestTypedRepoSchemaPrimaryKeys(t *testing.T) {
+	tests := []struct {
+		write          func(db *gorm.DB) error
+		expEnrollments []enrollment
+		expCourses     []course
+	}{
+		// It should update by the composite primary key
+		{
+			write: func(db *gorm.DB) error {
+				return TypedRepo[enrollment]{}.Updates(db, [][]interface{}{{1, 10}, {2, 20}}, map[string]interface{}{"grade": "A"}, QueryOption{})
+			},
+			expEnrollments: []enrollment{{1, 10, "A"}, {1, 20, "B"}, {2, 20, "A"}},
+			expCourses:     []course{{"C10", "Go"}, {"C20", "SQL"}},
+		},
+		{
+			write: func(db *gorm.DB) error {
+				return Repo{Model: enrollment{}}.Updates(db, [][]interface{}{{1, 20}, {2, 20}}, map[string]interface{}{"grade": "A"}, QueryOption{})
+			},
+			expEnrollments: []enrollment{{1, 10, "B"}, {1, 20, "A"}, {2, 20, "A"}},
+			expCourses:     []course{{"C10", "Go"}, {"C20", "SQL"}},
+		},
+		// It should update by the primary key not named id
+		{
+			write: func(db *gorm.DB) error {
+				return Repo{}.Updates(db, []string{"C20"}, &course{Title: "Databases"}, QueryOption{})
+			},
+			expEnrollments: []enrollment{{1, 10, "B"}, {1, 20, "B"}, {2, 20, "B"}},
+			expCourses:     []course{{"C10", "Go"}, {"C20", "Databases"}},
+		},
+		// It should still update by the keys given
+		{
+			write: func(db *gorm.DB) error {
+				return TypedRepo[enrollment]{}.Updates(db, []uint32{20}, map[string]interface{}{"grade": "C"}, QueryOption{Keys: []string{"CourseID"}})
+			},
+			expEnrollments: []enrollment{{1, 10, "B"}, {1, 20, "C"}, {2, 20, "C"}},
+			expCourses:     []course{{"C10", "Go"}, {"C20", "SQL"}},
+		},
+		{
+			write: func(db *gorm.DB) error {
+				return TypedRepo[enrollment]{}.Delete(db, [][]interface{}{{1, 20}}, QueryOption{})
+			},
+			expEnrollments: []enrollment{{1, 10, "B"}, {2, 20, "B"}},
+			expCourses:     []course{{"C10", "Go"}, {"C20", "SQL"}},
+		},
+	}
+
+	for _, tc := range tests {
+		func() {
+			req := require.New(t)
+
+			db := openFixture(req, []interface{}{&enrollment{}, &course{}},
+				[]enrollment{{1, 10, "B"}, {1, 20, "B"}, {2, 20, "B"}},
+				[]course{{"C10", "Go"}, {"C20", "SQL"}},
+			)
+			defer closeDb(db)
+
+			req.Nil(tc.write(db))
+
+			enrollments, err := TypedRepo[enrollment]{}.Get(db, nil, QueryOption{Sorts: []Sort{{Field: "StudentID"}, {Field: "CourseID"}}})
+			req.Nil(err)
+			req.Equal(tc.expEnrollments, enrollments)
+
+			courses, err := TypedRepo[course]{}.Get(db, []string{"C10", "C20"}, QueryOption{Sorts: []Sort{{Field: "Code"}}})
+			req.Nil(err)
+			req.Equal(tc.expCourses, courses)
+		}()
+	}
+}
@@ -0,0 +1,87 @@
+package pingorm
+
+import (
+	"strings"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"gorm.io/gorm/schema"
+)
+
+type NullsOrder string
+
+const (
+	NullsFirst NullsOrder = "first"
+	NullsLast  NullsOrder = "last"
+)
+
+// Sort orders the rows by Field, Nulls is left to the database when empty.
+type Sort struct {
+	Field string
+	Desc  bool
+	Nulls NullsOrder
+}
+
+type sortColumn struct {
+	field *schema.Field
+	desc  bool
+	nulls NullsOrder
+}
+
+func resolveSorts(db *gorm.DB, modelSchema *schema.Schema, sorts []Sort) ([]sortColumn, error) {
+	columns := make([]sortColumn, 0, len(sorts))
+	for _, sort := range sorts {
+		colName := db.NamingStrategy.ColumnName("", sort.Field)
+		field, ok := modelSchema.FieldsByDBName[colName]
+		if !ok {
+			return nil, invalidArgument("field %s is not a column of %s", sort.Field, modelSchema.Name)
+		}
+		if sort.Nulls != "" && sort.Nulls != NullsFirst && sort.Nulls != NullsLast {
+			return nil, invalidArgument("unsupported nulls order %s", sort.Nulls)
+		}
+		columns = append(columns, sortColumn{field: field, desc: sort.Desc, nulls: sort.Nulls})
+	}
+	return columns, nil
+}
+
+// orderBy appends the columns to the ORDER BY clause of db, nulls ordering
+// is emulated by a CASE expression as MySQL and SQL Server have no NULLS
+// FIRST/LAST, and SQL Server orders by no "col IS NULL" either.
+func orderBy(db *gorm.DB, columns []sortColumn) *gorm.DB {
+	for _, col := range columns {
+		if col.nulls != "" {
+			db = db.Order(clause.OrderByColumn{
+				Column: clause.Column{Name: "CASE WHEN " + db.Statement.Quote(col.field.DBName) + " IS NULL THEN 1 ELSE 0 END", Raw: true},
+				Desc:   col.nulls == NullsFirst,
+			})
+		}
+		db = db.Order(clause.OrderByColumn{Column: clause.Column{Name: col.field.DBName}, Desc: col.desc})
+	}
+	return db
+}
+
+// preload preloads the association of name ordered by sorts, name may be
+// nested i.e: "Books.Editor"
+func preload(db *gorm.DB, modelSchema *schema.Schema, name string, sorts []Sort) (*gorm.DB, error) {
+	if len(sorts) == 0 {
+		return db.Preload(name), nil
+	}
+
+	relSchema := modelSchema
+	for _, relName := range strings.Split(name, ".") {
+		rel, ok := relSchema.Relationships.Relations[relName]
+		if !ok {
+			return nil, invalidArgument("%s is not an association of %s", name, modelSchema.Name)
+		}
+		relSchema = rel.FieldSchema
+	}
+
+	columns, err := resolveSorts(db, relSchema, sorts)
+	if err != nil {
+		return nil, err
+	}
+
+	return db.Preload(name, func(tx *gorm.DB) *gorm.DB {
+		return orderBy(tx, columns)
+	}), nil
+}
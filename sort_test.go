@@ -0,0 +1,242 @@
+package pingorm
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+)
+
+func TestResolveSorts(t *testing.T) {
+	tests := []struct {
+		model      interface{}
+		sorts      []Sort
+		expColumns []string
+		expErr     error
+	}{
+		{
+			model:      &Book{},
+			sorts:      []Sort{{Field: "PublishDate", Desc: true}, {Field: "ID"}},
+			expColumns: []string{"publish_date", "id"},
+		},
+		{
+			model:  &Book{},
+			sorts:  []Sort{{Field: "Author"}},
+			expErr: &InvalidArgumentError{Reason: "field Author is not a column of Book"},
+		},
+		{
+			model:  &Book{},
+			sorts:  []Sort{{Field: "Title", Nulls: "middle"}},
+			expErr: &InvalidArgumentError{Reason: "unsupported nulls order middle"},
+		},
+	}
+
+	for _, tc := range tests {
+		func() {
+			req := require.New(t)
+
+			db, err := OpenDb(dbConString)
+			req.Nil(err)
+
+			modelSchema, err := parseSchema(db, tc.model)
+			req.Nil(err)
+
+			columns, errResolve := resolveSorts(db, modelSchema, tc.sorts)
+			req.Equal(tc.expErr, errResolve)
+
+			var colNames []string
+			for _, col := range columns {
+				colNames = append(colNames, col.field.DBName)
+			}
+			req.Equal(tc.expColumns, colNames)
+		}()
+	}
+}
+
+func TestTypedRepoGetSorted(t *testing.T) {
+
+	seeds := []interface{}{
+		&Author{ID: 1, Name: "Henglong"},
+		&Author{ID: 2, Name: "Vicheka"},
+		&Editor{ID: 1, Name: "Vichheka"},
+		&Book{ID: 1, AuthorID: 1, EditorID: 1, Title: "A"},
+		&Book{ID: 2, AuthorID: 1, EditorID: 1, Title: "C"},
+		&Book{ID: 3, AuthorID: 1, EditorID: 1, Title: "B"},
+		&Book{ID: 4, AuthorID: 2, EditorID: 1, Title: "B"},
+	}
+
+	tests := []struct {
+		queryParams QueryOption
+		expGot      []Book
+		expPageInfo PageInfo
+		expErr      error
+	}{
+		// It should order by Title desc then ID desc
+		{
+			queryParams: QueryOption{
+				SelectedFields: []string{"ID", "Title"},
+				Sorts:          []Sort{{Field: "Title", Desc: true}, {Field: "ID", Desc: true}},
+			},
+			expGot: []Book{
+				{ID: 2, Title: "C"},
+				{ID: 4, Title: "B"},
+				{ID: 3, Title: "B"},
+				{ID: 1, Title: "A"},
+			},
+		},
+		// It should page by Title tie-broken by ID
+		{
+			queryParams: QueryOption{
+				SelectedFields: []string{"ID", "Title"},
+				Sorts:          []Sort{{Field: "Title"}},
+				Pagination:     &Pagination{Limit: 2, Keyset: true},
+			},
+			expGot: []Book{
+				{ID: 1, Title: "A"},
+				{ID: 3, Title: "B"},
+			},
+			expPageInfo: PageInfo{HasMore: true, NextCursor: "WyJCIiwzXQ"},
+		},
+		{
+			queryParams: QueryOption{
+				SelectedFields: []string{"ID", "Title"},
+				Sorts:          []Sort{{Field: "Title"}},
+				Pagination:     &Pagination{Limit: 2, Keyset: true, Cursor: "WyJCIiwzXQ"},
+			},
+			expGot: []Book{
+				{ID: 4, Title: "B"},
+				{ID: 2, Title: "C"},
+			},
+		},
+		{
+			queryParams: QueryOption{
+				Sorts:      []Sort{{Field: "Title", Nulls: NullsLast}},
+				Pagination: &Pagination{Limit: 2, Keyset: true},
+			},
+			expErr: &InvalidArgumentError{Reason: "keyset pagination does not support nulls ordering"},
+		},
+		// It should refuse to page by a nullable field or a field left out of the rows
+		{
+			queryParams: QueryOption{
+				Sorts:      []Sort{{Field: "PublishDate"}},
+				Pagination: &Pagination{Limit: 1, Keyset: true},
+			},
+			expErr: &InvalidArgumentError{Reason: "keyset pagination does not support the nullable field PublishDate"},
+		},
+		{
+			queryParams: QueryOption{
+				SelectedFields: []string{"ID"},
+				Sorts:          []Sort{{Field: "Title"}},
+				Pagination:     &Pagination{Limit: 2, Keyset: true},
+			},
+			expErr: &InvalidArgumentError{Reason: "keyset pagination requires the field Title to be selected"},
+		},
+		{
+			queryParams: QueryOption{
+				OmittedFields: []string{"id"},
+				Sorts:         []Sort{{Field: "Title"}},
+				Pagination:    &Pagination{Limit: 2, Keyset: true},
+			},
+			expErr: &InvalidArgumentError{Reason: "keyset pagination requires the field ID to be selected"},
+		},
+	}
+
+	for _, tc := range tests {
+		func() {
+			req := require.New(t)
+
+			cleanTables()
+
+			db, err := OpenDb(dbConString)
+			req.Nil(err)
+			db = db.Debug()
+
+			for _, seed := range seeds {
+				err = db.Create(seed).Error
+				req.Nil(err)
+			}
+
+			got, pageInfo, errGet := TypedRepo[Book]{}.GetPage(db, nil, tc.queryParams)
+			req.Equal(tc.expErr, errGet)
+			if tc.expErr == nil {
+				req.Equal(tc.expGot, got)
+			}
+			req.Equal(tc.expPageInfo, pageInfo)
+		}()
+	}
+}
+
+func TestTypedRepoGetPreloadSorted(t *testing.T) {
+	req := require.New(t)
+
+	cleanTables()
+
+	db, err := OpenDb(dbConString)
+	req.Nil(err)
+	db = db.Debug()
+
+	seeds := []interface{}{
+		&Author{ID: 1, Name: "Henglong"},
+		&Editor{ID: 1, Name: "Vichheka"},
+		&Book{ID: 1, AuthorID: 1, EditorID: 1, Title: "A"},
+		&Book{ID: 2, AuthorID: 1, EditorID: 1, Title: "C"},
+		&Book{ID: 3, AuthorID: 1, EditorID: 1, Title: "B"},
+	}
+	for _, seed := range seeds {
+		err = db.Create(seed).Error
+		req.Nil(err)
+	}
+
+	got, err := TypedRepo[Author]{}.Get(db, []uint32{1}, QueryOption{
+		PreloadedFields: []string{"Books"},
+		PreloadSorts:    map[string][]Sort{"Books": {{Field: "Title", Desc: true}}},
+	})
+	req.Nil(err)
+	req.Len(got, 1)
+
+	var titles []string
+	for _, book := range got[0].Books {
+		titles = append(titles, book.Title)
+	}
+	req.Equal([]string{"C", "B", "A"}, titles)
+
+	_, err = TypedRepo[Author]{}.Get(db, []uint32{1}, QueryOption{
+		PreloadedFields: []string{"Editors"},
+		PreloadSorts:    map[string][]Sort{"Editors": {{Field: "Name"}}},
+	})
+	req.Equal(&InvalidArgumentError{Reason: "Editors is not an association of Author"}, err)
+}
+
+func TestOrderByNulls(t *testing.T) {
+	tests := []struct {
+		open   func(req *require.Assertions) *gorm.DB
+		expSQL string
+	}{
+		// It should order the nulls by an expression SQL Server takes too
+		{
+			open:   openMySQLDialector,
+			expSQL: "SELECT * FROM `book` ORDER BY CASE WHEN `publish_date` IS NULL THEN 1 ELSE 0 END DESC,`publish_date`",
+		},
+		{
+			open:   openSQLServerDialector,
+			expSQL: `SELECT * FROM "book" ORDER BY CASE WHEN "publish_date" IS NULL THEN 1 ELSE 0 END DESC,"publish_date"`,
+		},
+	}
+
+	for _, tc := range tests {
+		func() {
+			req := require.New(t)
+
+			db := tc.open(req)
+			defer closeDb(db)
+
+			modelSchema, err := parseSchema(db, &Book{})
+			req.Nil(err)
+			columns, err := resolveSorts(db, modelSchema, []Sort{{Field: "PublishDate", Nulls: NullsFirst}})
+			req.Nil(err)
+
+			stmt := orderBy(db.Unscoped().Model(&Book{}), columns).Find(&[]Book{}).Statement
+			req.Equal(tc.expSQL, stmt.SQL.String())
+		}()
+	}
+}
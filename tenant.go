@@ -0,0 +1,284 @@
+package pingorm
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+const (
+	tenantColumn        = "org_id"
+	skipTenantScopeKey  = "app:skip_tenant_scope"
+	tenantUnscopedKey   = "app:tenant_unscoped"
+	tenantScopeCallback = "app:tenant_scope"
+)
+
+var (
+	ErrCrossTenant   = errors.New("cross-tenant write")
+	ErrMissingTenant = errors.New("tenant scope requires an org id")
+)
+
+// CrossTenantError is reported by a write matching the rows of another org
+// than OrgID, it is ErrCrossTenant to errors.Is.
+type CrossTenantError struct {
+	Table string
+	OrgID string
+}
+
+func (err *CrossTenantError) Error() string {
+	return fmt.Sprintf("cross-tenant write on %s outside org %s", err.Table, err.OrgID)
+}
+
+func (err *CrossTenantError) Is(target error) bool {
+	return target == ErrCrossTenant
+}
+
+type (
+	// TenantOption audits the statements skipping the tenant scope by
+	// OnSkip, they are logged as warnings when OnSkip is nil.
+	TenantOption struct {
+		OnSkip func(ctx context.Context, skip TenantSkip)
+	}
+
+	TenantSkip struct {
+		Identity AuditIdentity
+		Table    string
+		Reason   string
+	}
+)
+
+// SkipTenantScope lifts the tenant scope off the statements of db, i.e: for
+// admin jobs. The reason is required and handed to TenantOption.OnSkip.
+func SkipTenantScope(db *gorm.DB, reason string) *gorm.DB {
+	return db.Set(skipTenantScopeKey, reason)
+}
+
+// RegisterTenantScope scopes every statement on a model with an org_id column
+// to the org resolved by provider, replacing any registered scope. Reads and
+// writes of other orgs' rows are filtered out, besides writes matching such
+// rows fail with a CrossTenantError. A statement without any org fails with
+// ErrMissingTenant. Raw SQL is left unscoped.
+func RegisterTenantScope(db *gorm.DB, provider AuditIdentityProvider, option TenantOption) {
+	scope := tenantScope{provider: provider, option: option}
+
+	if queryCallback := db.Callback().Query(); queryCallback.Get(tenantScopeCallback) == nil {
+		queryCallback.Before("gorm:query").Register(tenantScopeCallback, scope.scopeRead)
+	} else {
+		queryCallback.Replace(tenantScopeCallback, scope.scopeRead)
+	}
+
+	if rowCallback := db.Callback().Row(); rowCallback.Get(tenantScopeCallback) == nil {
+		rowCallback.Before("gorm:row").Register(tenantScopeCallback, scope.scopeRead)
+	} else {
+		rowCallback.Replace(tenantScopeCallback, scope.scopeRead)
+	}
+
+	if createCallback := db.Callback().Create(); createCallback.Get(tenantScopeCallback) == nil {
+		createCallback.Before("gorm:before_create").Register(tenantScopeCallback, scope.checkCreate)
+	} else {
+		createCallback.Replace(tenantScopeCallback, scope.checkCreate)
+	}
+
+	if updateCallback := db.Callback().Update(); updateCallback.Get(tenantScopeCallback) == nil {
+		updateCallback.Before("gorm:update").Register(tenantScopeCallback, scope.scopeWrite)
+	} else {
+		updateCallback.Replace(tenantScopeCallback, scope.scopeWrite)
+	}
+
+	if deleteCallback := db.Callback().Delete(); deleteCallback.Get(tenantScopeCallback) == nil {
+		deleteCallback.Before("gorm:delete").Register(tenantScopeCallback, scope.scopeWrite)
+	} else {
+		deleteCallback.Replace(tenantScopeCallback, scope.scopeWrite)
+	}
+}
+
+type tenantScope struct {
+	provider AuditIdentityProvider
+	option   TenantOption
+}
+
+// resolveOrgID reports false when tx is not to be scoped, tx fails when it
+// must be but no org is resolved.
+func (scope tenantScope) resolveOrgID(tx *gorm.DB) (string, bool) {
+	if tx.Error != nil || tx.Statement.Schema == nil || tx.Statement.SQL.Len() > 0 {
+		return "", false
+	}
+	if _, ok := tx.Statement.Schema.FieldsByDBName[tenantColumn]; !ok {
+		return "", false
+	}
+	if _, ok := tx.Get(tenantUnscopedKey); ok {
+		return "", false
+	}
+
+	identity, _ := resolveAuditIdentity(tx, scope.provider)
+	if val, ok := tx.Get(skipTenantScopeKey); ok {
+		reason, _ := val.(string)
+		if reason == "" {
+			tx.AddError(invalidArgument("skipping the tenant scope requires a reason"))
+			return "", false
+		}
+
+		skip := TenantSkip{Identity: identity, Table: tx.Statement.Table, Reason: reason}
+		if scope.option.OnSkip != nil {
+			scope.option.OnSkip(tx.Statement.Context, skip)
+		} else {
+			tx.Logger.Warn(tx.Statement.Context, "tenant scope skipped on %s by user %s of org %s: %s",
+				skip.Table, identity.UserID, identity.OrgID, reason)
+		}
+		return "", false
+	}
+
+	if identity.OrgID == "" {
+		tx.AddError(ErrMissingTenant)
+		return "", false
+	}
+	return identity.OrgID, true
+}
+
+func (scope tenantScope) scopeRead(tx *gorm.DB) {
+	if orgID, ok := scope.resolveOrgID(tx); ok {
+		tx.Statement.AddClause(clause.Where{Exprs: []clause.Expression{tenantEq(orgID)}})
+	}
+}
+
+// checkCreate rejects the rows of another org ahead of the audit callbacks,
+// the rows without any org are left to them to stamp. An upsert is rejected
+// as well when its rows conflict with rows of another org.
+func (scope tenantScope) checkCreate(tx *gorm.DB) {
+	orgID, ok := scope.resolveOrgID(tx)
+	if !ok {
+		return
+	}
+
+	if assignsOtherOrg(tx, tx.Statement.Dest, orgID) {
+		tx.AddError(&CrossTenantError{Table: tx.Statement.Table, OrgID: orgID})
+		return
+	}
+
+	onConflict, ok := tx.Statement.Clauses["ON CONFLICT"].Expression.(clause.OnConflict)
+	if !ok || onConflict.DoNothing {
+		return
+	}
+
+	conds := rowsKeyConds(tx, tx.Statement.ReflectValue)
+	if found, err := matchesOtherOrg(tx, conds, orgID); err != nil {
+		tx.AddError(err)
+	} else if found {
+		tx.AddError(&CrossTenantError{Table: tx.Statement.Table, OrgID: orgID})
+	}
+}
+
+// scopeWrite fails the update or delete of tx when its conditions match rows
+// of another org, it scopes tx to the org otherwise.
+func (scope tenantScope) scopeWrite(tx *gorm.DB) {
+	orgID, ok := scope.resolveOrgID(tx)
+	if !ok {
+		return
+	}
+
+	// Moving rows to another org is as much a cross-tenant write
+	if assignsOtherOrg(tx, tx.Statement.Dest, orgID) {
+		tx.AddError(&CrossTenantError{Table: tx.Statement.Table, OrgID: orgID})
+		return
+	}
+
+	var conds []clause.Expression
+	if where, ok := tx.Statement.Clauses["WHERE"].Expression.(clause.Where); ok {
+		conds = append(conds, where.Exprs...)
+	}
+	conds = append(conds, primaryKeyConds(tx, tx.Statement.ReflectValue)...)
+
+	// Leave the statement without conditions to ErrMissingWhereClause
+	if len(conds) == 0 && !tx.AllowGlobalUpdate {
+		return
+	}
+
+	if len(conds) > 0 {
+		if found, err := matchesOtherOrg(tx, conds, orgID); err != nil {
+			tx.AddError(err)
+			return
+		} else if found {
+			tx.AddError(&CrossTenantError{Table: tx.Statement.Table, OrgID: orgID})
+			return
+		}
+	}
+
+	tx.Statement.AddClause(clause.Where{Exprs: []clause.Expression{tenantEq(orgID)}})
+}
+
+// matchesOtherOrg reports whether conds match any row of another org than
+// orgID, soft deleted rows included.
+func matchesOtherOrg(tx *gorm.DB, conds []clause.Expression, orgID string) (bool, error) {
+	var found []int
+	err := tx.Session(&gorm.Session{NewDB: true}).
+		Set(tenantUnscopedKey, true).
+		Set(usePrimaryKey, true).
+		Table(tx.Statement.Table).
+		Where(clause.And(conds...)).
+		Not(tenantEq(orgID)).
+		Select("1").Limit(1).Find(&found).Error
+	return len(found) > 0, err
+}
+
+// primaryKeyConds are the conditions gorm derives from the primary keys of
+// the row being updated or deleted.
+func primaryKeyConds(tx *gorm.DB, row reflect.Value) []clause.Expression {
+	row = reflect.Indirect(row)
+	if row.Kind() != reflect.Struct {
+		return nil
+	}
+
+	var conds []clause.Expression
+	for _, field := range tx.Statement.Schema.PrimaryFields {
+		if value, isZero := field.ValueOf(tx.Statement.Context, row); !isZero {
+			conds = append(conds, clause.Eq{Column: clause.Column{Table: clause.CurrentTable, Name: field.DBName}, Value: value})
+		}
+	}
+	return conds
+}
+
+func tenantEq(orgID string) clause.Expression {
+	return clause.Eq{Column: clause.Column{Table: clause.CurrentTable, Name: tenantColumn}, Value: orgID}
+}
+
+// assignsOtherOrg reports whether the rows or the values of dest set the
+// org_id of another org than orgID.
+func assignsOtherOrg(tx *gorm.DB, dest interface{}, orgID string) bool {
+	field := tx.Statement.Schema.FieldsByDBName[tenantColumn]
+	isOtherOrg := func(value interface{}) bool {
+		v := reflect.Indirect(reflect.ValueOf(value))
+		return !v.IsValid() || fmt.Sprint(v.Interface()) != orgID
+	}
+
+	switch values := dest.(type) {
+	case map[string]interface{}:
+		for name, value := range values {
+			if (name == field.DBName || name == field.Name) && isOtherOrg(value) {
+				return true
+			}
+		}
+		return false
+	}
+
+	rows := reflect.Indirect(reflect.ValueOf(dest))
+	switch rows.Kind() {
+	case reflect.Slice, reflect.Array:
+		for i := 0; i < rows.Len(); i++ {
+			if assignsOtherOrg(tx, rows.Index(i).Interface(), orgID) {
+				return true
+			}
+		}
+	case reflect.Struct:
+		if rows.Type() != tx.Statement.Schema.ModelType {
+			return false
+		}
+		if value, isZero := field.ValueOf(tx.Statement.Context, rows); !isZero && isOtherOrg(value) {
+			return true
+		}
+	}
+	return false
+}
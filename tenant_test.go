@@ -0,0 +1,248 @@
+package pingorm
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+)
+
+type tenantNote struct {
+	ID      uint32 `gorm:"primaryKey"`
+	OrgID   string
+	Title   string
+	Deleted gorm.DeletedAt
+}
+
+// openTenantDb seeds the notes of two orgs and scopes the returned db to
+// org01, the seed db is left unscoped to check the rows.
+func openTenantDb(req *require.Assertions, option TenantOption) (db *gorm.DB, seedDb *gorm.DB) {
+	seedDb = openFixture(req, []interface{}{&tenantNote{}}, []tenantNote{
+		{ID: 1, OrgID: "org01", Title: "Note 1"},
+		{ID: 2, OrgID: "org02", Title: "Note 2"},
+		{ID: 3, OrgID: "org01", Title: "Note 3"},
+	})
+
+	db, err := OpenDb(dbConString)
+	req.Nil(err)
+	RegisterAuditCallbacks(db, "user01", "org01")
+	RegisterTenantScope(db, AuditIdentity{UserID: "user01", OrgID: "org01"}, option)
+	return db, seedDb
+}
+
+func TestTenantScopeRead(t *testing.T) {
+	tests := []struct {
+		ctx      context.Context
+		ids      interface{}
+		option   QueryOption
+		expNotes []tenantNote
+		expTotal int64
+		expErr   error
+	}{
+		// It should filter out the notes of other orgs
+		{
+			ctx: context.Background(),
+			ids: []uint32{1, 2, 3},
+			expNotes: []tenantNote{
+				{ID: 1, OrgID: "org01", Title: "Note 1"},
+				{ID: 3, OrgID: "org01", Title: "Note 3"},
+			},
+			expTotal: 2,
+		},
+		{
+			ctx:      ContextWithAuditIdentity(context.Background(), AuditIdentity{UserID: "user02", OrgID: "org02"}),
+			ids:      []uint32{1, 2, 3},
+			expNotes: []tenantNote{{ID: 2, OrgID: "org02", Title: "Note 2"}},
+			expTotal: 1,
+		},
+		// It should fail without any org to scope by
+		{
+			ctx:    ContextWithAuditIdentity(context.Background(), AuditIdentity{UserID: "user02"}),
+			ids:    []uint32{1, 2, 3},
+			expErr: ErrMissingTenant,
+		},
+	}
+
+	for _, tc := range tests {
+		func() {
+			req := require.New(t)
+
+			db, seedDb := openTenantDb(req, TenantOption{})
+			defer closeDb(seedDb)
+			defer closeDb(db)
+
+			repo := TypedRepo[tenantNote]{}
+			notes, err := repo.GetContext(tc.ctx, db, tc.ids, tc.option)
+			req.ErrorIs(err, tc.expErr)
+			if tc.expErr != nil {
+				return
+			}
+			req.Equal(tc.expNotes, notes)
+
+			total, err := repo.Count(db.WithContext(tc.ctx), tc.ids, tc.option)
+			req.Nil(err)
+			req.Equal(tc.expTotal, total)
+		}()
+	}
+}
+
+func TestTenantScopeWrite(t *testing.T) {
+	repo := TypedRepo[tenantNote]{}
+
+	tests := []struct {
+		write    func(db *gorm.DB) error
+		expErr   error
+		expNotes []tenantNote
+		expSkips []TenantSkip
+	}{
+		// It should delete the notes of the org
+		{
+			write: func(db *gorm.DB) error {
+				return repo.Delete(db, []uint32{1}, QueryOption{})
+			},
+			expNotes: []tenantNote{
+				{ID: 2, OrgID: "org02", Title: "Note 2"},
+				{ID: 3, OrgID: "org01", Title: "Note 3"},
+			},
+		},
+		// It should fail to delete any note of another org
+		{
+			write: func(db *gorm.DB) error {
+				return repo.Delete(db, []uint32{1, 2}, QueryOption{})
+			},
+			expErr: ErrCrossTenant,
+			expNotes: []tenantNote{
+				{ID: 1, OrgID: "org01", Title: "Note 1"},
+				{ID: 2, OrgID: "org02", Title: "Note 2"},
+				{ID: 3, OrgID: "org01", Title: "Note 3"},
+			},
+		},
+		{
+			write: func(db *gorm.DB) error {
+				return repo.Updates(db, []uint32{1, 3}, map[string]interface{}{"title": "Updated"}, QueryOption{})
+			},
+			expNotes: []tenantNote{
+				{ID: 1, OrgID: "org01", Title: "Updated"},
+				{ID: 2, OrgID: "org02", Title: "Note 2"},
+				{ID: 3, OrgID: "org01", Title: "Updated"},
+			},
+		},
+		{
+			write: func(db *gorm.DB) error {
+				return repo.Updates(db, []uint32{2}, map[string]interface{}{"title": "Updated"}, QueryOption{})
+			},
+			expErr: ErrCrossTenant,
+			expNotes: []tenantNote{
+				{ID: 1, OrgID: "org01", Title: "Note 1"},
+				{ID: 2, OrgID: "org02", Title: "Note 2"},
+				{ID: 3, OrgID: "org01", Title: "Note 3"},
+			},
+		},
+		// It should fail to move a note to another org
+		{
+			write: func(db *gorm.DB) error {
+				_, err := repo.Update(db, tenantNote{ID: 1, OrgID: "org02"}, QueryOption{})
+				return err
+			},
+			expErr: ErrCrossTenant,
+			expNotes: []tenantNote{
+				{ID: 1, OrgID: "org01", Title: "Note 1"},
+				{ID: 2, OrgID: "org02", Title: "Note 2"},
+				{ID: 3, OrgID: "org01", Title: "Note 3"},
+			},
+		},
+		// It should stamp the org on create and fail to create for another org
+		{
+			write: func(db *gorm.DB) error {
+				_, err := repo.Create(db, tenantNote{ID: 4, Title: "Note 4"}, QueryOption{})
+				return err
+			},
+			expNotes: []tenantNote{
+				{ID: 1, OrgID: "org01", Title: "Note 1"},
+				{ID: 2, OrgID: "org02", Title: "Note 2"},
+				{ID: 3, OrgID: "org01", Title: "Note 3"},
+				{ID: 4, OrgID: "org01", Title: "Note 4"},
+			},
+		},
+		{
+			write: func(db *gorm.DB) error {
+				_, err := repo.Create(db, tenantNote{ID: 4, OrgID: "org02", Title: "Note 4"}, QueryOption{})
+				return err
+			},
+			expErr: ErrCrossTenant,
+			expNotes: []tenantNote{
+				{ID: 1, OrgID: "org01", Title: "Note 1"},
+				{ID: 2, OrgID: "org02", Title: "Note 2"},
+				{ID: 3, OrgID: "org01", Title: "Note 3"},
+			},
+		},
+		// It should fail to upsert over a note of another org
+		{
+			write: func(db *gorm.DB) error {
+				_, err := repo.Upsert(db, []tenantNote{{ID: 2, OrgID: "org01", Title: "Upserted"}}, QueryOption{SelectedFields: []string{"title"}})
+				return err
+			},
+			expErr: ErrCrossTenant,
+			expNotes: []tenantNote{
+				{ID: 1, OrgID: "org01", Title: "Note 1"},
+				{ID: 2, OrgID: "org02", Title: "Note 2"},
+				{ID: 3, OrgID: "org01", Title: "Note 3"},
+			},
+		},
+		// It should audit the statements skipping the scope
+		{
+			write: func(db *gorm.DB) error {
+				return repo.Delete(SkipTenantScope(db, "purge org02"), []uint32{2}, QueryOption{})
+			},
+			expNotes: []tenantNote{
+				{ID: 1, OrgID: "org01", Title: "Note 1"},
+				{ID: 3, OrgID: "org01", Title: "Note 3"},
+			},
+			expSkips: []TenantSkip{
+				{Identity: AuditIdentity{UserID: "user01", OrgID: "org01"}, Table: "tenant_note", Reason: "purge org02"},
+			},
+		},
+		{
+			write: func(db *gorm.DB) error {
+				return repo.Delete(SkipTenantScope(db, ""), []uint32{2}, QueryOption{})
+			},
+			expErr: &InvalidArgumentError{Reason: "skipping the tenant scope requires a reason"},
+			expNotes: []tenantNote{
+				{ID: 1, OrgID: "org01", Title: "Note 1"},
+				{ID: 2, OrgID: "org02", Title: "Note 2"},
+				{ID: 3, OrgID: "org01", Title: "Note 3"},
+			},
+		},
+	}
+
+	for _, tc := range tests {
+		func() {
+			req := require.New(t)
+
+			var skips []TenantSkip
+			db, seedDb := openTenantDb(req, TenantOption{
+				OnSkip: func(ctx context.Context, skip TenantSkip) {
+					skips = append(skips, skip)
+				},
+			})
+			defer closeDb(seedDb)
+			defer closeDb(db)
+
+			err := tc.write(db)
+			if tc.expErr == ErrCrossTenant {
+				req.ErrorIs(err, ErrCrossTenant)
+				var crossTenantErr *CrossTenantError
+				req.ErrorAs(err, &crossTenantErr)
+				req.Equal("org01", crossTenantErr.OrgID)
+			} else {
+				req.Equal(tc.expErr, err)
+			}
+			req.Equal(tc.expSkips, skips)
+
+			var notes []tenantNote
+			req.Nil(seedDb.Order("id").Find(&notes).Error)
+			req.Equal(tc.expNotes, notes)
+		}()
+	}
+}
@@ -0,0 +1,168 @@
+package pingorm
+
+import (
+	"reflect"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"gorm.io/gorm/schema"
+)
+
+// Restore brings back the soft deleted rows whose keys are in sliceOfIDs.
+func (repo Repo) Restore(_db interface{}, sliceOfIDs interface{}, option QuerySelector) error {
+	if isEmpty, err := isEmptySlice(sliceOfIDs); err != nil || isEmpty {
+		return err
+	}
+	return restore(_db.(*gorm.DB), repo.Model, sliceOfIDs, option)
+}
+
+// Purge hard deletes the rows soft deleted longer than retention ago and
+// matching the option filters, option.GetBatchSize() rows per statement. It
+// reports the number of rows purged.
+func (repo Repo) Purge(_db interface{}, retention time.Duration, option QuerySelector) (int64, error) {
+	return purge(_db.(*gorm.DB), repo.Model, retention, option)
+}
+
+// Restore brings back the soft deleted rows whose keys are in sliceOfIDs.
+func (repo TypedRepo[T]) Restore(db *gorm.DB, sliceOfIDs interface{}, option QuerySelector) error {
+	if isEmpty, err := isEmptySlice(sliceOfIDs); err != nil || isEmpty {
+		return err
+	}
+	return restore(db, new(T), sliceOfIDs, option)
+}
+
+// Purge hard deletes the rows soft deleted longer than retention ago and
+// matching the option filters, option.GetBatchSize() rows per statement. It
+// reports the number of rows purged.
+func (repo TypedRepo[T]) Purge(db *gorm.DB, retention time.Duration, option QuerySelector) (int64, error) {
+	return purge(db, new(T), retention, option)
+}
+
+func restore(db *gorm.DB, model interface{}, sliceOfIDs interface{}, option QuerySelector) error {
+	modelSchema, err := parseSchema(db, model)
+	if err != nil {
+		return err
+	}
+	field, err := softDeleteField(modelSchema)
+	if err != nil {
+		return err
+	}
+	cascades, err := cascadesOf(modelSchema, option.GetCascades())
+	if err != nil {
+		return err
+	}
+	if len(cascades) > 0 {
+		return restoreCascading(db, model, modelSchema, field, cascades, sliceOfIDs, option)
+	}
+
+	if db, err = whereKeys(db.Unscoped(), modelSchema, sliceOfIDs, option); err != nil {
+		return err
+	}
+	return db.Model(model).
+		Where(clause.Neq{Column: clause.Column{Table: clause.CurrentTable, Name: field.DBName}, Value: nil}).
+		Update(field.DBName, nil).Error
+}
+
+// purge deletes by primary keys the batches it finds, no statement spans more
+// than one batch so that large tables are not locked for long.
+func purge(db *gorm.DB, model interface{}, retention time.Duration, option QuerySelector) (int64, error) {
+	modelSchema, err := parseSchema(db, model)
+	if err != nil {
+		return 0, err
+	}
+	field, err := softDeleteField(modelSchema)
+	if err != nil {
+		return 0, err
+	}
+	if len(modelSchema.PrimaryFields) == 0 {
+		return 0, gorm.ErrPrimaryKeyRequired
+	}
+
+	batchSize := option.GetBatchSize()
+	if batchSize <= 0 {
+		batchSize = defaultBatchSize
+	}
+
+	query, err := whereKeysAndFilters(db.Unscoped(), modelSchema, nil, option)
+	if err != nil {
+		return 0, err
+	}
+	query = query.Model(model).
+		Select(modelSchema.PrimaryFieldDBNames).
+		Where(clause.Lt{Column: clause.Column{Table: clause.CurrentTable, Name: field.DBName}, Value: db.NowFunc().Add(-retention)}).
+		Limit(batchSize)
+	for _, name := range modelSchema.PrimaryFieldDBNames {
+		query = query.Order(clause.OrderByColumn{Column: clause.Column{Name: name}})
+	}
+
+	var purged int64
+	for {
+		rows := reflect.New(reflect.SliceOf(modelSchema.ModelType))
+		if err := query.Session(&gorm.Session{}).Find(rows.Interface()).Error; err != nil {
+			return purged, err
+		}
+		if rows.Elem().Len() == 0 {
+			return purged, nil
+		}
+
+		result := db.Unscoped().Where(keysExpr(db, modelSchema, rows.Elem())).Delete(model)
+		if result.Error != nil {
+			return purged, result.Error
+		}
+		purged += result.RowsAffected
+
+		if rows.Elem().Len() < batchSize {
+			return purged, nil
+		}
+	}
+}
+
+// scopeDeleted lifts the soft delete scope off db for IncludeDeleted, and
+// narrows it down to the soft deleted rows for OnlyDeleted.
+func scopeDeleted(db *gorm.DB, modelSchema *schema.Schema, option QuerySelector) (*gorm.DB, error) {
+	if option.IsOnlyDeleted() {
+		field, err := softDeleteField(modelSchema)
+		if err != nil {
+			return nil, err
+		}
+		return db.Unscoped().Where(clause.Neq{Column: clause.Column{Table: clause.CurrentTable, Name: field.DBName}, Value: nil}), nil
+	}
+	if option.IsIncludeDeleted() {
+		return db.Unscoped(), nil
+	}
+	return db, nil
+}
+
+func softDeleteField(modelSchema *schema.Schema) (*schema.Field, error) {
+	for _, field := range modelSchema.Fields {
+		if field.FieldType == reflect.TypeOf(gorm.DeletedAt{}) {
+			return field, nil
+		}
+	}
+	return nil, invalidArgument("%s has no soft delete field", modelSchema.Name)
+}
+
+// keysExpr matches the rows by their primary keys.
+func keysExpr(db *gorm.DB, modelSchema *schema.Schema, rows reflect.Value) clause.Expression {
+	ctx := db.Statement.Context
+	if len(modelSchema.PrimaryFields) == 1 {
+		field := modelSchema.PrimaryFields[0]
+		values := make([]interface{}, rows.Len())
+		for i := range values {
+			values[i], _ = field.ValueOf(ctx, reflect.Indirect(rows.Index(i)))
+		}
+		return clause.IN{Column: clause.Column{Table: clause.CurrentTable, Name: field.DBName}, Values: values}
+	}
+
+	orExprs := make([]clause.Expression, rows.Len())
+	for i := range orExprs {
+		andExprs := make([]clause.Expression, len(modelSchema.PrimaryFields))
+		for j, field := range modelSchema.PrimaryFields {
+			value, _ := field.ValueOf(ctx, reflect.Indirect(rows.Index(i)))
+			andExprs[j] = clause.Eq{Column: clause.Column{Table: clause.CurrentTable, Name: field.DBName}, Value: value}
+		}
+		orExprs[i] = clause.And(andExprs...)
+	}
+	return clause.Or(orExprs...)
+}
@@ -0,0 +1,199 @@
+package pingorm
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+)
+
+// seedTrash creates 4 authors, ID 2 is soft deleted just now while 3 and 4
+// are deleted 2 and 3 days ago.
+func seedTrash(req *require.Assertions, db *gorm.DB) {
+	for _, author := range []Author{
+		{ID: 1, Name: "Henglong"},
+		{ID: 2, Name: "Vicheka"},
+		{ID: 3, Name: "Vichheka"},
+		{ID: 4, Name: "Dara"},
+	} {
+		req.Nil(db.Create(&author).Error)
+	}
+
+	now := time.Now()
+	req.Nil(db.Delete(&Author{}, 2).Error)
+	req.Nil(db.Model(&Author{ID: 3}).Update("deleted", now.Add(-48*time.Hour)).Error)
+	req.Nil(db.Model(&Author{ID: 4}).Update("deleted", now.Add(-72*time.Hour)).Error)
+}
+
+func TestGetDeleted(t *testing.T) {
+	tests := []struct {
+		queryParams QueryOption
+		expAuthors  []Author
+		expErr      error
+	}{
+		{
+			queryParams: QueryOption{},
+			expAuthors:  []Author{{ID: 1, Name: "Henglong"}},
+		},
+		{
+			queryParams: QueryOption{IncludeDeleted: true},
+			expAuthors: []Author{
+				{ID: 1, Name: "Henglong"},
+				{ID: 2, Name: "Vicheka"},
+				{ID: 3, Name: "Vichheka"},
+				{ID: 4, Name: "Dara"},
+			},
+		},
+		{
+			queryParams: QueryOption{OnlyDeleted: true},
+			expAuthors: []Author{
+				{ID: 2, Name: "Vicheka"},
+				{ID: 3, Name: "Vichheka"},
+				{ID: 4, Name: "Dara"},
+			},
+		},
+		{
+			queryParams: QueryOption{OnlyDeleted: true, Filters: []Filter{Like("Name", "Vi%")}},
+			expAuthors: []Author{
+				{ID: 2, Name: "Vicheka"},
+				{ID: 3, Name: "Vichheka"},
+			},
+		},
+	}
+
+	for _, tc := range tests {
+		func() {
+			req := require.New(t)
+
+			cleanTables()
+
+			db, err := OpenDb(dbConString)
+			req.Nil(err)
+			seedTrash(req, db)
+
+			tc.queryParams.SelectedFields = []string{"ID", "Name"}
+			tc.queryParams.Sorts = []Sort{{Field: "ID"}}
+			authors, err := TypedRepo[Author]{}.Get(db, nil, tc.queryParams)
+			req.Equal(tc.expErr, err)
+			req.Equal(tc.expAuthors, authors)
+
+			total, err := TypedRepo[Author]{}.Count(db, nil, tc.queryParams)
+			req.Nil(err)
+			req.Equal(int64(len(tc.expAuthors)), total)
+		}()
+	}
+}
+
+func TestOnlyDeletedWithoutSoftDelete(t *testing.T) {
+	req := require.New(t)
+
+	db, err := OpenDb(dbConString)
+	req.Nil(err)
+
+	_, err = TypedRepo[versionedBook]{}.Get(db, nil, QueryOption{OnlyDeleted: true})
+	req.Equal(&InvalidArgumentError{Reason: "versionedBook has no soft delete field"}, err)
+}
+
+func TestRestore(t *testing.T) {
+	tests := []struct {
+		inputIDs   interface{}
+		expAuthors []Author
+	}{
+		{
+			inputIDs: []uint32{2, 3},
+			expAuthors: []Author{
+				{ID: 1, Name: "Henglong"},
+				{ID: 2, Name: "Vicheka"},
+				{ID: 3, Name: "Vichheka"},
+			},
+		},
+		// It should leave the rows not deleted as they are
+		{
+			inputIDs:   []uint32{1},
+			expAuthors: []Author{{ID: 1, Name: "Henglong"}},
+		},
+		{
+			inputIDs:   []uint32{},
+			expAuthors: []Author{{ID: 1, Name: "Henglong"}},
+		},
+	}
+
+	for _, tc := range tests {
+		func() {
+			req := require.New(t)
+
+			cleanTables()
+
+			db, err := OpenDb(dbConString)
+			req.Nil(err)
+			seedTrash(req, db)
+
+			req.Nil(Repo{Model: &Author{}}.Restore(db, tc.inputIDs, QueryOption{}))
+
+			authors, err := TypedRepo[Author]{}.Get(db, nil, QueryOption{SelectedFields: []string{"ID", "Name"}, Sorts: []Sort{{Field: "ID"}}})
+			req.Nil(err)
+			req.Equal(tc.expAuthors, authors)
+		}()
+	}
+}
+
+func TestPurge(t *testing.T) {
+	tests := []struct {
+		retention   time.Duration
+		queryParams QueryOption
+		expPurged   int64
+		expAuthors  []Author
+	}{
+		// It should purge in batches the rows deleted before the retention
+		{
+			retention:   24 * time.Hour,
+			queryParams: QueryOption{BatchSize: 1},
+			expPurged:   2,
+			expAuthors: []Author{
+				{ID: 1, Name: "Henglong"},
+				{ID: 2, Name: "Vicheka"},
+			},
+		},
+		{
+			retention:   60 * time.Hour,
+			queryParams: QueryOption{},
+			expPurged:   1,
+			expAuthors: []Author{
+				{ID: 1, Name: "Henglong"},
+				{ID: 2, Name: "Vicheka"},
+				{ID: 3, Name: "Vichheka"},
+			},
+		},
+		{
+			retention:   24 * time.Hour,
+			queryParams: QueryOption{Filters: []Filter{Eq("Name", "Dara")}},
+			expPurged:   1,
+			expAuthors: []Author{
+				{ID: 1, Name: "Henglong"},
+				{ID: 2, Name: "Vicheka"},
+				{ID: 3, Name: "Vichheka"},
+			},
+		},
+	}
+
+	for _, tc := range tests {
+		func() {
+			req := require.New(t)
+
+			cleanTables()
+
+			db, err := OpenDb(dbConString)
+			req.Nil(err)
+			seedTrash(req, db)
+
+			purged, err := TypedRepo[Author]{}.Purge(db, tc.retention, tc.queryParams)
+			req.Nil(err)
+			req.Equal(tc.expPurged, purged)
+
+			authors, err := TypedRepo[Author]{}.Get(db, nil, QueryOption{IncludeDeleted: true, SelectedFields: []string{"ID", "Name"}, Sorts: []Sort{{Field: "ID"}}})
+			req.Nil(err)
+			req.Equal(tc.expAuthors, authors)
+		}()
+	}
+}
@@ -0,0 +1,99 @@
+package pingorm
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/go-sql-driver/mysql"
+	"gorm.io/gorm"
+)
+
+const (
+	defaultTxMaxRetries = 3
+	defaultTxBackoff    = 50 * time.Millisecond
+)
+
+// TxOption configures the retries of WithTxOption on deadlock and lock
+// wait timeout, Backoff is doubled on each retry.
+type TxOption struct {
+	MaxRetries int
+	Backoff    time.Duration
+}
+
+type txHooks struct {
+	afterCommit []func()
+}
+
+// WithTx runs fn in a transaction of db with the default TxOption.
+func WithTx(ctx context.Context, db *gorm.DB, fn func(tx *gorm.DB) error) error {
+	return WithTxOption(ctx, db, TxOption{}, fn)
+}
+
+// WithTxOption runs fn in a transaction of db, it commits when fn returns nil
+// and rolls back otherwise. Called with the tx of an enclosing WithTx, it runs
+// fn in a savepoint and leaves the retries to the outermost transaction. The
+// hooks registered by AfterCommit run only once the outermost one commits.
+func WithTxOption(ctx context.Context, db *gorm.DB, option TxOption, fn func(tx *gorm.DB) error) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	if parentHooks, ok := db.Statement.Context.Value(txHooksContextKey).(*txHooks); ok {
+		hooks := &txHooks{}
+		err := db.WithContext(context.WithValue(ctx, txHooksContextKey, hooks)).Transaction(fn)
+		if err == nil {
+			parentHooks.afterCommit = append(parentHooks.afterCommit, hooks.afterCommit...)
+		}
+		return contextError(ctx, err)
+	}
+
+	maxRetries := option.MaxRetries
+	if maxRetries <= 0 {
+		maxRetries = defaultTxMaxRetries
+	}
+	backoff := option.Backoff
+	if backoff <= 0 {
+		backoff = defaultTxBackoff
+	}
+
+	for attempt := 0; ; attempt++ {
+		hooks := &txHooks{}
+		err := db.WithContext(context.WithValue(ctx, txHooksContextKey, hooks)).Transaction(fn)
+		if err == nil {
+			for _, hook := range hooks.afterCommit {
+				hook()
+			}
+			return nil
+		}
+
+		if attempt >= maxRetries || !isRetryableTxError(err) {
+			return contextError(ctx, err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(backoff << attempt):
+		}
+	}
+}
+
+// AfterCommit registers hook to run after the WithTx transaction of tx
+// commits, it runs hook right away when tx is not in WithTx.
+func AfterCommit(tx *gorm.DB, hook func()) {
+	if hooks, ok := tx.Statement.Context.Value(txHooksContextKey).(*txHooks); ok {
+		hooks.afterCommit = append(hooks.afterCommit, hook)
+		return
+	}
+	hook()
+}
+
+func isRetryableTxError(err error) bool {
+	if errors.Is(translateDbError(err), ErrDeadlock) {
+		return true
+	}
+	var mysqlErr *mysql.MySQLError
+	// ER_LOCK_WAIT_TIMEOUT
+	return errors.As(err, &mysqlErr) && mysqlErr.Number == 1205
+}
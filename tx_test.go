@@ -0,0 +1,126 @@
+package pingorm
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/go-sql-driver/mysql"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+)
+
+func TestWithTx(t *testing.T) {
+
+	deadlock := &mysql.MySQLError{Number: 1213, Message: "Deadlock found when trying to get lock"}
+
+	tests := []struct {
+		failures    []error
+		nestedErr   error
+		expDbAuthor []Author
+		expHooks    []string
+		expAttempts int
+		expErr      error
+	}{
+		// It should commit both the outer and the nested transaction
+		{
+			expDbAuthor: []Author{
+				{ID: 1, Name: "Henglong"},
+				{ID: 2, Name: "Vicheka"},
+			},
+			expHooks:    []string{"outer", "nested"},
+			expAttempts: 1,
+		},
+		// It should roll back the savepoint only along with its hooks
+		{
+			nestedErr: errors.New("nested failed"),
+			expDbAuthor: []Author{
+				{ID: 1, Name: "Henglong"},
+			},
+			expHooks:    []string{"outer"},
+			expAttempts: 1,
+		},
+		// It should retry on deadlock
+		{
+			failures: []error{deadlock, deadlock},
+			expDbAuthor: []Author{
+				{ID: 1, Name: "Henglong"},
+				{ID: 2, Name: "Vicheka"},
+			},
+			expHooks:    []string{"outer", "nested"},
+			expAttempts: 3,
+		},
+		// It should give up after the max retries without running the hooks
+		{
+			failures:    []error{deadlock, deadlock, deadlock},
+			expDbAuthor: []Author{},
+			expAttempts: 3,
+			expErr:      deadlock,
+		},
+		// It should not retry other errors
+		{
+			failures:    []error{errors.New("failed")},
+			expDbAuthor: []Author{},
+			expAttempts: 1,
+			expErr:      errors.New("failed"),
+		},
+	}
+
+	for _, tc := range tests {
+		func() {
+			req := require.New(t)
+
+			cleanTables()
+
+			db, err := OpenDb(dbConString)
+			req.Nil(err)
+			db = db.Debug()
+
+			var hooks []string
+			attempts := 0
+			ctx := context.Background()
+			repo := TypedRepo[Author]{}
+
+			errTx := WithTxOption(ctx, db, TxOption{MaxRetries: 2, Backoff: time.Millisecond}, func(tx *gorm.DB) error {
+				attempts++
+				if _, err := repo.Create(tx, Author{ID: 1, Name: "Henglong"}, QueryOption{}); err != nil {
+					return err
+				}
+				AfterCommit(tx, func() { hooks = append(hooks, "outer") })
+
+				_ = WithTx(ctx, tx, func(nestedTx *gorm.DB) error {
+					if _, err := repo.Create(nestedTx, Author{ID: 2, Name: "Vicheka"}, QueryOption{}); err != nil {
+						return err
+					}
+					AfterCommit(nestedTx, func() { hooks = append(hooks, "nested") })
+					return tc.nestedErr
+				})
+
+				if attempts <= len(tc.failures) {
+					return tc.failures[attempts-1]
+				}
+				return nil
+			})
+
+			req.Equal(tc.expErr, errTx)
+			req.Equal(tc.expAttempts, attempts)
+			req.Equal(tc.expHooks, hooks)
+
+			var dbAuthors []Author
+			db.Model(&Author{}).Select("ID", "Name").Find(&dbAuthors)
+			req.Equal(tc.expDbAuthor, dbAuthors)
+		}()
+	}
+}
+
+func TestAfterCommitOutsideTx(t *testing.T) {
+	req := require.New(t)
+
+	db, err := OpenDb(dbConString)
+	req.Nil(err)
+
+	ran := false
+	AfterCommit(db, func() { ran = true })
+	req.True(ran)
+}
@@ -0,0 +1,265 @@
+package pingorm
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"sort"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"gorm.io/gorm/schema"
+)
+
+// UpsertOption is how Upsert updates the rows conflicting on QueryOption.Keys,
+// the primary key when there are none. MySQL has no conflict target, its
+// "ON DUPLICATE KEY" fires on any unique key and ignores the Keys.
+type UpsertOption struct {
+	// UpdatedFields are set to the values proposed, the SelectedFields are
+	// when nil.
+	UpdatedFields []string
+	// UpdateExprs sets fields to expressions i.e:
+	// {"Count": clause.Expr{SQL: "count + ?", Vars: []interface{}{Excluded("Count")}}}
+	UpdateExprs map[string]clause.Expression
+	// DoNothing leaves the conflicting rows as they are.
+	DoNothing bool
+}
+
+// UpsertOutcome is what an upsert did to one of its rows.
+type UpsertOutcome string
+
+const (
+	UpsertInserted  UpsertOutcome = "inserted"
+	UpsertUpdated   UpsertOutcome = "updated"
+	UpsertUnchanged UpsertOutcome = "unchanged"
+)
+
+// Excluded is the value an upsert proposed for field, to be used in
+// UpsertOption.UpdateExprs.
+func Excluded(field string) clause.Expression {
+	return excludedColumn(field)
+}
+
+type excludedColumn string
+
+func (excluded excludedColumn) Build(builder clause.Builder) {
+	column := clause.Column{Name: string(excluded)}
+	if stmt, ok := builder.(*gorm.Statement); ok {
+		column.Name = stmt.NamingStrategy.ColumnName("", column.Name)
+		if Dialect(stmt.Dialector.Name()) == DialectMySQL {
+			builder.WriteString("VALUES(")
+			builder.WriteQuoted(column)
+			builder.WriteString(")")
+			return
+		}
+	}
+	builder.WriteString("excluded.")
+	builder.WriteQuoted(column)
+}
+
+// UpsertReport upserts as Upsert does and reports the outcome of each row.
+// MySQL conflicts on any unique key whatever QueryOption.Keys, the outcomes
+// are told by the Keys only so a row conflicting on another unique key is
+// reported inserted there.
+func (repo Repo) UpsertReport(_db interface{}, slice interface{}, option QuerySelector) (sliceOfResult interface{}, outcomes []UpsertOutcome, err error) {
+	if sliceOfResult, err = convertToSliceOfStructTypes(slice); err != nil {
+		return nil, nil, err
+	}
+
+	outcomes, err = upsertReport(_db.(*gorm.DB), sliceOfResult, option)
+	return sliceOfResult, outcomes, err
+}
+
+// UpsertReport upserts as Upsert does and reports the outcome of each row.
+// MySQL conflicts on any unique key whatever QueryOption.Keys, the outcomes
+// are told by the Keys only so a row conflicting on another unique key is
+// reported inserted there.
+func (repo TypedRepo[T]) UpsertReport(db *gorm.DB, models []T, option QuerySelector) ([]T, []UpsertOutcome, error) {
+	if len(models) == 0 {
+		return nil, nil, invalidArgument("empty slices")
+	}
+
+	outcomes, err := upsertReport(db, &models, option)
+	if err != nil {
+		return nil, nil, err
+	}
+	return models, outcomes, nil
+}
+
+// onConflict is the ON CONFLICT clause of an upsert of modelSchema.
+func onConflict(db *gorm.DB, modelSchema *schema.Schema, option QuerySelector) clause.OnConflict {
+	upsertOption := option.GetUpsert()
+	if upsertOption == nil {
+		upsertOption = &UpsertOption{}
+	}
+
+	fields := upsertOption.UpdatedFields
+	if fields == nil {
+		fields = option.GetSelectedFields()
+	}
+	var set clause.Set
+	for _, field := range fields {
+		set = append(set, clause.Assignment{
+			Column: clause.Column{Name: db.NamingStrategy.ColumnName("", field)},
+			Value:  Excluded(field),
+		})
+	}
+
+	exprFields := make([]string, 0, len(upsertOption.UpdateExprs))
+	for field := range upsertOption.UpdateExprs {
+		exprFields = append(exprFields, field)
+	}
+	sort.Strings(exprFields)
+	for _, field := range exprFields {
+		set = append(set, clause.Assignment{
+			Column: clause.Column{Name: db.NamingStrategy.ColumnName("", field)},
+			Value:  upsertOption.UpdateExprs[field],
+		})
+	}
+
+	// An empty update list leaves the conflicting rows as they are, which
+	// MySQL does anyway, the other dialects require "DO NOTHING" explicitly.
+	doNothing := upsertOption.DoNothing || len(set) == 0
+	if doNothing {
+		set = nil
+	}
+	return clause.OnConflict{
+		Columns:   conflictColumns(db, modelSchema, option.GetKeys()),
+		DoUpdates: set,
+		DoNothing: doNothing,
+	}
+}
+
+func conflictColumns(db *gorm.DB, modelSchema *schema.Schema, keys []string) []clause.Column {
+	if len(keys) == 0 {
+		return primaryKeyColumns(modelSchema)
+	}
+	columns := make([]clause.Column, len(keys))
+	for i, key := range keys {
+		columns[i] = clause.Column{Name: db.NamingStrategy.ColumnName("", key)}
+	}
+	return columns
+}
+
+// upsertReport tells the rows inserted from the ones conflicting by looking
+// up their conflict keys ahead of the upsert, in the same transaction.
+func upsertReport(db *gorm.DB, slice interface{}, option QuerySelector) ([]UpsertOutcome, error) {
+	modelSchema, err := parseSchema(db, slice)
+	if err != nil {
+		return nil, err
+	}
+	conflict := onConflict(db, modelSchema, option)
+	fields := conflictFields(modelSchema, conflict.Columns)
+	ctx := db.Statement.Context
+	rows := reflect.ValueOf(slice)
+
+	var outcomes []UpsertOutcome
+	err = db.Transaction(func(tx *gorm.DB) error {
+		var rowConds []clause.Expression
+		eachRow(rows, func(row reflect.Value) {
+			if conds := conflictKeyConds(ctx, fields, row); len(conds) == len(fields) {
+				rowConds = append(rowConds, clause.And(conds...))
+			}
+		})
+		found := map[string]bool{}
+		size := chunkSize(tx, modelSchema, option)
+		for start := 0; start < len(rowConds); start += size {
+			end := start + size
+			if end > len(rowConds) {
+				end = len(rowConds)
+			}
+
+			existing := reflect.New(reflect.SliceOf(modelSchema.ModelType))
+			if err := tx.Session(&gorm.Session{NewDB: true}).
+				Set(tenantUnscopedKey, true).
+				Set(usePrimaryKey, true).
+				Unscoped().
+				Model(reflect.New(modelSchema.ModelType).Interface()).
+				Where(clause.Or(rowConds[start:end]...)).
+				Find(existing.Interface()).Error; err != nil {
+				return err
+			}
+			eachRow(existing, func(row reflect.Value) {
+				found[conflictKey(ctx, fields, row)] = true
+			})
+		}
+
+		outcomes = nil
+		eachRow(rows, func(row reflect.Value) {
+			// A row without its primary key is always inserted
+			if len(conflictKeyConds(ctx, fields, row)) < len(fields) {
+				outcomes = append(outcomes, UpsertInserted)
+				return
+			}
+
+			key := conflictKey(ctx, fields, row)
+			switch {
+			case !found[key]:
+				outcomes = append(outcomes, UpsertInserted)
+			case conflict.DoNothing:
+				outcomes = append(outcomes, UpsertUnchanged)
+			default:
+				outcomes = append(outcomes, UpsertUpdated)
+			}
+			found[key] = true
+		})
+
+		return upsert(tx, slice, option)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return outcomes, nil
+}
+
+// statementConflictFields are the fields of the conflict target of the
+// upsert of tx, the primary fields for a plain create.
+func statementConflictFields(tx *gorm.DB) []*schema.Field {
+	onConflict, _ := tx.Statement.Clauses["ON CONFLICT"].Expression.(clause.OnConflict)
+	return conflictFields(tx.Statement.Schema, onConflict.Columns)
+}
+
+func conflictFields(modelSchema *schema.Schema, columns []clause.Column) []*schema.Field {
+	if len(columns) == 0 {
+		return modelSchema.PrimaryFields
+	}
+
+	fields := make([]*schema.Field, 0, len(columns))
+	for _, column := range columns {
+		if field := modelSchema.LookUpField(column.Name); field != nil {
+			fields = append(fields, field)
+		}
+	}
+	return fields
+}
+
+// conflictKeyConds matches row by the values of fields, the conditions are
+// partial when the primary key of row is.
+func conflictKeyConds(ctx context.Context, fields []*schema.Field, row reflect.Value) []clause.Expression {
+	row = reflect.Indirect(row)
+	if row.Kind() != reflect.Struct {
+		return nil
+	}
+
+	var conds []clause.Expression
+	for _, field := range fields {
+		value, isZero := field.ValueOf(ctx, row)
+		if isZero && field.PrimaryKey {
+			continue
+		}
+		conds = append(conds, clause.Eq{Column: clause.Column{Table: clause.CurrentTable, Name: field.DBName}, Value: value})
+	}
+	return conds
+}
+
+func conflictKey(ctx context.Context, fields []*schema.Field, row reflect.Value) string {
+	values := make([]interface{}, len(fields))
+	for i, field := range fields {
+		value, _ := field.ValueOf(ctx, row)
+		if v := reflect.Indirect(reflect.ValueOf(value)); v.IsValid() {
+			value = v.Interface()
+		}
+		values[i] = value
+	}
+	return fmt.Sprintf("%#v", values)
+}
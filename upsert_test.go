@@ -0,0 +1,90 @@
+package pingorm
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm/clause"
+)
+
+type stockItem struct {
+	ID        uint32 `gorm:"primaryKey"`
+	Sku       string `gorm:"uniqueIndex:idx_stock_item_sku"`
+	Warehouse string `gorm:"uniqueIndex:idx_stock_item_sku"`
+	Title     string
+	Count     int
+}
+
+func TestUpsertConflictTarget(t *testing.T) {
+	input := []stockItem{
+		{Sku: "A", Warehouse: "PP", Title: "Pen", Count: 5},
+		{Sku: "B", Warehouse: "PP", Title: "Book", Count: 2},
+	}
+
+	tests := []struct {
+		queryParams QueryOption
+		expOutcomes []UpsertOutcome
+		expItems    []stockItem
+	}{
+		// It should match on the keys and update the fields to update only
+		{
+			queryParams: QueryOption{
+				Keys:   []string{"Sku", "Warehouse"},
+				Upsert: &UpsertOption{UpdatedFields: []string{"Title"}},
+			},
+			expOutcomes: []UpsertOutcome{UpsertUpdated, UpsertInserted},
+			expItems: []stockItem{
+				{ID: 1, Sku: "A", Warehouse: "PP", Title: "Pen", Count: 1},
+				{ID: 2, Sku: "A", Warehouse: "SR", Title: "Pencil", Count: 3},
+				{ID: 3, Sku: "B", Warehouse: "PP", Title: "Book", Count: 2},
+			},
+		},
+		{
+			queryParams: QueryOption{
+				Keys: []string{"Sku", "Warehouse"},
+				Upsert: &UpsertOption{UpdateExprs: map[string]clause.Expression{
+					"Count": clause.Expr{SQL: "? + ?", Vars: []interface{}{clause.Column{Table: clause.CurrentTable, Name: "count"}, Excluded("Count")}},
+				}},
+			},
+			expOutcomes: []UpsertOutcome{UpsertUpdated, UpsertInserted},
+			expItems: []stockItem{
+				{ID: 1, Sku: "A", Warehouse: "PP", Title: "Pencil", Count: 6},
+				{ID: 2, Sku: "A", Warehouse: "SR", Title: "Pencil", Count: 3},
+				{ID: 3, Sku: "B", Warehouse: "PP", Title: "Book", Count: 2},
+			},
+		},
+		// It should leave the conflicting rows as they are
+		{
+			queryParams: QueryOption{
+				Keys:   []string{"Sku", "Warehouse"},
+				Upsert: &UpsertOption{UpdatedFields: []string{"Title"}, DoNothing: true},
+			},
+			expOutcomes: []UpsertOutcome{UpsertUnchanged, UpsertInserted},
+			expItems: []stockItem{
+				{ID: 1, Sku: "A", Warehouse: "PP", Title: "Pencil", Count: 1},
+				{ID: 2, Sku: "A", Warehouse: "SR", Title: "Pencil", Count: 3},
+				{ID: 3, Sku: "B", Warehouse: "PP", Title: "Book", Count: 2},
+			},
+		},
+	}
+
+	for _, tc := range tests {
+		func() {
+			req := require.New(t)
+
+			db := openFixture(req, []interface{}{&stockItem{}}, []stockItem{
+				{ID: 1, Sku: "A", Warehouse: "PP", Title: "Pencil", Count: 1},
+				{ID: 2, Sku: "A", Warehouse: "SR", Title: "Pencil", Count: 3},
+			})
+			defer closeDb(db)
+
+			_, outcomes, err := TypedRepo[stockItem]{}.UpsertReport(db, append([]stockItem{}, input...), tc.queryParams)
+			req.Nil(err)
+			req.Equal(tc.expOutcomes, outcomes)
+
+			var items []stockItem
+			req.Nil(db.Order("sku, warehouse").Find(&items).Error)
+			req.Equal(tc.expItems, items)
+		}()
+	}
+}